@@ -0,0 +1,143 @@
+package violetear
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MediaType is a media range of the Accept header
+type MediaType struct {
+	Type   string
+	Params map[string]string
+	Q      float64
+}
+
+// ParseAccept returns the media types of the Accept header ordered by
+// q-value, media types with the same q-value keep the header order and the
+// malformed ones are skipped.
+func ParseAccept(header string) []MediaType {
+	var types []MediaType
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		mt := MediaType{
+			Type: strings.TrimSpace(fields[0]),
+			Q:    1,
+		}
+		if i := strings.Index(mt.Type, "/"); i < 1 || i == len(mt.Type)-1 {
+			continue
+		}
+		valid := true
+		for _, f := range fields[1:] {
+			kv := strings.SplitN(f, "=", 2)
+			if len(kv) != 2 {
+				valid = false
+				break
+			}
+			k := strings.ToLower(strings.TrimSpace(kv[0]))
+			v := strings.Trim(strings.TrimSpace(kv[1]), `"`)
+			if k == "q" {
+				q, err := strconv.ParseFloat(v, 64)
+				if err != nil || q < 0 || q > 1 {
+					valid = false
+					break
+				}
+				mt.Q = q
+				continue
+			}
+			if mt.Params == nil {
+				mt.Params = map[string]string{}
+			}
+			mt.Params[k] = v
+		}
+		if valid {
+			types = append(types, mt)
+		}
+	}
+	sort.SliceStable(types, func(i, j int) bool {
+		return types[i].Q > types[j].Q
+	})
+	return types
+}
+
+// acceptVersion returns the version of the vendor media type with the
+// highest q-value, "application/vnd.violetear.v2" returns "violetear.v2" and
+// "application/vnd.api+json;version=2" returns "2". The version is the media
+// type after prefix, "application/vnd." if empty, without the structured
+// syntax suffix "+json" and suffix: "application/vnd.myapp.v2+json" returns
+// "2" using the prefix "application/vnd.myapp.v".
+func acceptVersion(header, prefix, suffix string) string {
+	if prefix == "" {
+		prefix = versionHeader
+	}
+	if !strings.Contains(header, prefix) {
+		return ""
+	}
+	for _, mt := range ParseAccept(header) {
+		if mt.Q == 0 || !strings.HasPrefix(mt.Type, prefix) {
+			continue
+		}
+		if v, ok := mt.Params["version"]; ok {
+			return v
+		}
+		v := mt.Type[len(prefix):]
+		if i := strings.Index(v, "+"); i != -1 {
+			v = v[:i]
+		}
+		return strings.TrimSuffix(v, suffix)
+	}
+	return ""
+}
+
+// acceptHeader returns the Accept header requesting version, the inverse of
+// acceptVersion
+func (r *Router) acceptHeader(version string) string {
+	prefix := r.AcceptVersionPrefix
+	if prefix == "" {
+		prefix = versionHeader
+	}
+	return prefix + version + r.AcceptVersionSuffix
+}
+
+// addVersion adds version to the registered versions keeping them sorted
+// from the highest to the lowest
+func (r *Router) addVersion(version string) {
+	for _, v := range r.versions {
+		if v == version {
+			return
+		}
+	}
+	r.versions = append(r.versions, version)
+	sort.SliceStable(r.versions, func(i, j int) bool {
+		return versionLess(r.versions[j], r.versions[i])
+	})
+}
+
+// versionLess compares the versions using the natural order, the numbers are
+// compared by value: "v2" < "v10"
+func versionLess(a, b string) bool {
+	for a != "" && b != "" {
+		ca, ra := versionChunk(a)
+		cb, rb := versionChunk(b)
+		if ca != cb {
+			na, errA := strconv.Atoi(ca)
+			nb, errB := strconv.Atoi(cb)
+			if errA == nil && errB == nil && na != nb {
+				return na < nb
+			}
+			return ca < cb
+		}
+		a, b = ra, rb
+	}
+	return len(a) < len(b)
+}
+
+// versionChunk returns the leading digits or non digits of s and the rest
+func versionChunk(s string) (string, string) {
+	digit := s[0] >= '0' && s[0] <= '9'
+	i := 1
+	for i < len(s) && (s[i] >= '0' && s[i] <= '9') == digit {
+		i++
+	}
+	return s[:i], s[i:]
+}
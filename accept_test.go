@@ -0,0 +1,237 @@
+package violetear
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseAccept(t *testing.T) {
+	tt := []struct {
+		header string
+		expect []MediaType
+	}{
+		{"", nil},
+		{"text/html", []MediaType{{"text/html", nil, 1}}},
+		{"text/html;q=0.5, application/json", []MediaType{
+			{"application/json", nil, 1},
+			{"text/html", nil, 0.5},
+		}},
+		{"application/vnd.api+json;version=2, */*;q=0.1", []MediaType{
+			{"application/vnd.api+json", map[string]string{"version": "2"}, 1},
+			{"*/*", nil, 0.1},
+		}},
+		{"a/b;q=0.2, c/d;q=0.2, e/f;q=0.9", []MediaType{
+			{"e/f", nil, 0.9},
+			{"a/b", nil, 0.2},
+			{"c/d", nil, 0.2},
+		}},
+		{`text/plain; Charset="utf-8"`, []MediaType{{"text/plain", map[string]string{"charset": "utf-8"}, 1}}},
+		{"text, /html, text/, text/html;q=x, text/html;q=2, text/html;foo, text/xml", []MediaType{{"text/xml", nil, 1}}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.header, func(t *testing.T) {
+			expectDeepEqual(t, ParseAccept(tc.header), tc.expect)
+		})
+	}
+}
+
+func TestAcceptVersion(t *testing.T) {
+	tt := []struct {
+		header string
+		expect string
+	}{
+		{"", ""},
+		{"application/json", ""},
+		{"application/vnd.violetear.v2", "violetear.v2"},
+		{"application/vnd.api+json;version=2, */*;q=0.1", "2"},
+		{"application/vnd.violetear.v1;q=0.5, application/vnd.violetear.v2", "violetear.v2"},
+		{"application/vnd.violetear.v1;q=0, text/html", ""},
+		{"text/html, application/vnd.violetear.v3;q=0.9", "violetear.v3"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.header, func(t *testing.T) {
+			expect(t, acceptVersion(tc.header, "", ""), tc.expect)
+		})
+	}
+}
+
+func TestAcceptVersionPrefix(t *testing.T) {
+	tt := []struct {
+		header string
+		prefix string
+		suffix string
+		expect string
+	}{
+		{"application/vnd.myapp.v2+json", "", "", "myapp.v2"},
+		{"application/vnd.myapp.v2+json", "application/vnd.myapp.v", "", "2"},
+		{"application/vnd.myapp.v2.1.0+json; charset=utf-8", "application/vnd.myapp.v", "", "2.1.0"},
+		{"application/vnd.github.v3.raw+json", "application/vnd.github.", ".raw", "v3"},
+		{"application/vnd.github.v3+json", "application/vnd.github.", ".raw", "v3"},
+		{"application/vnd.myapp-2.1.0-preview", "application/vnd.myapp-", "-preview", "2.1.0"},
+		{"application/vnd.myapp.v2+xml;version=3", "application/vnd.myapp.v", "", "3"},
+		{"application/vnd.other.v2+json", "application/vnd.myapp.v", "", ""},
+		{"application/vnd.api+json", "", "", "api"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.header, func(t *testing.T) {
+			expect(t, acceptVersion(tc.header, tc.prefix, tc.suffix), tc.expect)
+		})
+	}
+}
+
+func TestAcceptVersionRouter(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("users"))
+	})
+	router.HandleFunc("/users#2", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("users 2"))
+	})
+	for header, body := range map[string]string{
+		"application/vnd.api+json;version=2, */*;q=0.1": "users 2",
+		"*/*": "users",
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/users", nil)
+		req.Header.Set("Accept", header)
+		router.ServeHTTP(w, req)
+		expect(t, w.Body.String(), body)
+	}
+}
+
+func TestAcceptVersionPrefixRouter(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.AcceptVersionPrefix = "application/vnd.myapp.v"
+	router.AcceptVersionSuffix = "-preview"
+	router.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("users"))
+	})
+	router.HandleFunc("/users#2", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("users 2"))
+	})
+	for header, body := range map[string]string{
+		"application/vnd.myapp.v2+json":         "users 2",
+		"application/vnd.myapp.v2-preview+json": "users 2",
+		"application/json":                      "users",
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/users", nil)
+		req.Header.Set("Accept", header)
+		router.ServeHTTP(w, req)
+		expect(t, w.Body.String(), body)
+	}
+	expect(t, router.Test("GET", "/users", nil, "2").Body.String(), "users 2")
+}
+
+func TestDisableVersioning(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("users"))
+	})
+	router.HandleFunc("/users#2", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("users 2"))
+	})
+	router.HandleFunc("/reports", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("reports"))
+	})
+
+	tt := []struct {
+		disable bool
+		path    string
+		header  string
+		code    int
+		body    string
+	}{
+		{false, "/reports", "application/vnd.ms-excel", 404, "404 page not found\n"},
+		{true, "/reports", "application/vnd.ms-excel", 200, "reports"},
+		{false, "/users", "application/vnd.api+json;version=2", 200, "users 2"},
+		{true, "/users", "application/vnd.api+json;version=2", 200, "users"},
+	}
+	for _, tc := range tt {
+		router.DisableVersioning = tc.disable
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		req.Header.Set("Accept", tc.header)
+		router.ServeHTTP(w, req)
+		expect(t, w.Code, tc.code)
+		expect(t, w.Body.String(), tc.body)
+	}
+
+	// the query parameter is still used
+	router.VersionParam = "v"
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/users?v=2", nil)
+	router.ServeHTTP(w, req)
+	expect(t, w.Body.String(), "users 2")
+}
+
+func TestVersionLess(t *testing.T) {
+	tt := []struct {
+		a, b string
+		less bool
+	}{
+		{"1", "2", true},
+		{"2", "10", true},
+		{"v2", "v10", true},
+		{"v10", "v2", false},
+		{"violetear.v1", "violetear.v2", true},
+		{"1", "1", false},
+		{"1", "1.1", true},
+		{"1.10", "1.9", false},
+		{"a", "b", true},
+	}
+	for _, tc := range tt {
+		expect(t, versionLess(tc.a, tc.b), tc.less)
+	}
+}
+
+func TestFallbackToUnversioned(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.FallbackToUnversioned = true
+	text := func(s string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(s))
+		}
+	}
+	router.HandleFunc("/users#v2", text("users v2"))
+	router.HandleFunc("/users#v10", text("users v10"))
+	router.HandleFunc("/users#v1", text("users v1"))
+	router.HandleFunc("/items#v1", text("items v1"))
+	router.HandleFunc("/items", text("items"))
+	router.HandleFunc("/old#v1", text("old v1"))
+	expectDeepEqual(t, router.versions, []string{"v10", "v2", "v1"})
+
+	tt := []struct {
+		path    string
+		version string
+		code    int
+		body    string
+	}{
+		{"/users", "", 200, "users v10"},
+		{"/users", "violetear.v1", 404, "404 page not found\n"},
+		{"/items", "", 200, "items"},
+		{"/old", "", 200, "old v1"},
+		{"/none", "", 404, "404 page not found\n"},
+	}
+	for _, tc := range tt {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		if tc.version != "" {
+			req.Header.Set("Accept", "application/vnd."+tc.version)
+		}
+		router.ServeHTTP(w, req)
+		expect(t, w.Code, tc.code)
+		expect(t, w.Body.String(), tc.body)
+	}
+
+	router.FallbackToUnversioned = false
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/users", nil)
+	router.ServeHTTP(w, req)
+	expect(t, w.Code, 404)
+}
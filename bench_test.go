@@ -3,6 +3,7 @@
 package violetear
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -43,3 +44,35 @@ func BenchmarkRouterStaticWithName(b *testing.B) {
 	r, _ := http.NewRequest("GET", "/hello", nil)
 	benchRequest(b, router, r)
 }
+
+func BenchmarkRouterStaticSiblings(b *testing.B) {
+	router := New()
+	router.Verbose = false
+	for i := 0; i < 1000; i++ {
+		router.HandleFunc(fmt.Sprintf("/route%d", i), func(w http.ResponseWriter, r *http.Request) {}, "GET")
+	}
+	r, _ := http.NewRequest("GET", "/route999", nil)
+	benchRequest(b, router, r)
+}
+
+func BenchmarkRouterDynamicSiblings(b *testing.B) {
+	router := New()
+	router.Verbose = false
+	router.AddRegex(":word", `^\w+$`)
+	for i := 0; i < 1000; i++ {
+		router.HandleFunc(fmt.Sprintf("/route%d", i), func(w http.ResponseWriter, r *http.Request) {}, "GET")
+	}
+	router.HandleFunc("/:word", func(w http.ResponseWriter, r *http.Request) {}, "GET")
+	r, _ := http.NewRequest("GET", "/foo", nil)
+	benchRequest(b, router, r)
+}
+
+func BenchmarkRouterDynamic3Params(b *testing.B) {
+	router := New()
+	router.Verbose = false
+	router.AddRegex(":word", `^\w+$`)
+	router.AddRegex(":id", `^\d+$`)
+	router.HandleFunc("/test/:word/:id/items/:word", func(w http.ResponseWriter, r *http.Request) {}, "GET,HEAD")
+	r, _ := http.NewRequest("GET", "/test/foo/42/items/bar", nil)
+	benchRequest(b, router, r)
+}
@@ -0,0 +1,127 @@
+package violetear
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// mediaTypeMatch returns true if the media type t matches pattern, the
+// pattern can be a range "text/*" or "*/*"
+func mediaTypeMatch(pattern, t string) bool {
+	if pattern == "*/*" || pattern == t {
+		return true
+	}
+	if strings.HasSuffix(pattern, "/*") {
+		return strings.HasPrefix(t, pattern[:len(pattern)-1])
+	}
+	return false
+}
+
+// Consumes returns a middleware responding 415 Unsupported Media Type when
+// the request has a body and its Content-Type, without parameters like
+// charset, is not one of types, example:
+//
+//	router.HandleWithMiddleware("/users", h, []func(http.Handler) http.Handler{
+//	    Consumes("application/json"),
+//	}, "POST")
+//
+// Requests without body are not checked, a body without Content-Type is
+// rejected.
+func Consumes(types ...string) func(http.Handler) http.Handler {
+	allowed := make([]string, len(types))
+	for i, t := range types {
+		allowed[i] = strings.ToLower(strings.TrimSpace(t))
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength == 0 && r.Header.Get("Content-Type") == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if t, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err == nil {
+				for _, a := range allowed {
+					if mediaTypeMatch(a, t) {
+						next.ServeHTTP(w, r)
+						return
+					}
+				}
+			}
+			http.Error(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+		})
+	}
+}
+
+// mediaTypeSpecificity returns 2 for "text/html", 1 for "text/*" and 0 for
+// "*/*", the most specific media range of the Accept header sets the q-value
+func mediaTypeSpecificity(t string) int {
+	switch {
+	case t == "*/*":
+		return 0
+	case strings.HasSuffix(t, "/*"):
+		return 1
+	}
+	return 2
+}
+
+// negotiate returns the type with the highest q-value in the Accept header,
+// types with the same q-value keep their order, false if none is acceptable
+func negotiate(header string, types []string) (string, bool) {
+	if strings.TrimSpace(header) == "" {
+		return types[0], true
+	}
+	accept := ParseAccept(header)
+	var (
+		best  string
+		bestQ float64
+	)
+	for _, t := range types {
+		q, specificity := 0.0, -1
+		for _, mt := range accept {
+			r := strings.ToLower(mt.Type)
+			if s := mediaTypeSpecificity(r); s > specificity && mediaTypeMatch(r, t) {
+				q, specificity = mt.Q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = t, q
+		}
+	}
+	return best, bestQ > 0
+}
+
+// Produces returns a middleware responding 406 Not Acceptable when none of
+// types is acceptable by the Accept header of the request, the q-values and
+// ranges like "*/*" are used. The negotiated type, the first one if there is
+// no Accept header, is available to the handler with NegotiatedType.
+func Produces(types ...string) func(http.Handler) http.Handler {
+	produced := make([]string, len(types))
+	for i, t := range types {
+		produced[i] = strings.ToLower(strings.TrimSpace(t))
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept")
+			if len(produced) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			t, ok := negotiate(r.Header.Get("Accept"), produced)
+			if !ok {
+				http.Error(w, http.StatusText(http.StatusNotAcceptable), http.StatusNotAcceptable)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), producesKey, t)))
+		})
+	}
+}
+
+// NegotiatedType returns the media type negotiated by Produces, empty if the
+// route doesn't use it
+func NegotiatedType(r *http.Request) string {
+	if t, ok := r.Context().Value(producesKey).(string); ok {
+		return t
+	}
+	return ""
+}
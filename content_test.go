@@ -0,0 +1,93 @@
+package violetear
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestConsumes(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	mw := []func(http.Handler) http.Handler{Consumes("application/json", "text/*")}
+	router.HandleWithMiddleware("/users", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}), mw)
+
+	tt := []struct {
+		name        string
+		method      string
+		body        string
+		contentType string
+		code        int
+		expect      string
+	}{
+		{"json", "POST", "{}", "application/json", 200, "ok"},
+		{"json charset", "POST", "{}", "application/json; charset=utf-8", 200, "ok"},
+		{"case", "POST", "{}", "Application/JSON", 200, "ok"},
+		{"range", "POST", "hi", "text/plain", 200, "ok"},
+		{"disallowed", "POST", "<a/>", "application/xml", 415, "Unsupported Media Type\n"},
+		{"missing", "POST", "{}", "", 415, "Unsupported Media Type\n"},
+		{"malformed", "POST", "{}", "json;", 415, "Unsupported Media Type\n"},
+		{"no body", "GET", "", "", 200, "ok"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tc.method, "/users", strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, tc.code)
+			expect(t, w.Body.String(), tc.expect)
+		})
+	}
+}
+
+func TestProduces(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	mw := []func(http.Handler) http.Handler{Produces("application/json", "text/html")}
+	router.HandleWithMiddleware("/users", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(NegotiatedType(r)))
+	}), mw)
+
+	tt := []struct {
+		accept string
+		code   int
+		expect string
+	}{
+		{"", 200, "application/json"},
+		{"application/json", 200, "application/json"},
+		{"text/html", 200, "text/html"},
+		{"*/*", 200, "application/json"},
+		{"text/*", 200, "text/html"},
+		{"application/json;q=0.5, text/html", 200, "text/html"},
+		{"application/json, text/html;q=0.9", 200, "application/json"},
+		{"application/json;q=0.5, text/html;q=0.5", 200, "application/json"},
+		{"*/*;q=0.1, text/html;q=0", 200, "application/json"},
+		{"application/*;q=0, */*", 200, "text/html"},
+		{"application/json;q=0", 406, "Not Acceptable\n"},
+		{"image/png", 406, "Not Acceptable\n"},
+		{"text/plain, application/xml", 406, "Not Acceptable\n"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.accept, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/users", nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, tc.code)
+			expect(t, w.Body.String(), tc.expect)
+			expect(t, w.Header().Get("Vary"), "Accept")
+		})
+	}
+
+	// outside of Produces
+	req, _ := http.NewRequest("GET", "/", nil)
+	expect(t, NegotiatedType(req), "")
+}
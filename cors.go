@@ -0,0 +1,92 @@
+package violetear
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CORSOptions configuration for the CORS middleware
+type CORSOptions struct {
+	// AllowedOrigins list of origins allowed, "*" allows any origin.
+	AllowedOrigins []string
+
+	// AllowedMethods list of methods allowed, defaults to GET, HEAD, POST.
+	AllowedMethods []string
+
+	// AllowedHeaders list of headers allowed, if empty the headers requested
+	// on the preflight are allowed.
+	AllowedHeaders []string
+
+	// AllowCredentials allow cookies and HTTP authentication.
+	AllowCredentials bool
+
+	// MaxAge seconds the preflight response can be cached, 0 omits the header.
+	MaxAge int
+}
+
+// CORS returns a middleware handling Cross-Origin Resource Sharing, preflight
+// requests (OPTIONS with Access-Control-Request-Method) from an allowed
+// origin are answered with 204, other OPTIONS requests are passed to the next
+// handler so that AutoOptions still applies. Requests from not allowed
+// origins are passed without CORS headers.
+//
+//	router.Use(violetear.CORS(violetear.CORSOptions{
+//	    AllowedOrigins: []string{"https://example.com"},
+//	}))
+func CORS(opts CORSOptions) func(http.Handler) http.Handler {
+	methods := "GET, HEAD, POST"
+	if len(opts.AllowedMethods) > 0 {
+		methods = strings.ToUpper(strings.Join(opts.AllowedMethods, ", "))
+	}
+	headers := strings.Join(opts.AllowedHeaders, ", ")
+	anyOrigin := false
+	origins := map[string]bool{}
+	for _, o := range opts.AllowedOrigins {
+		if o == "*" {
+			anyOrigin = true
+		}
+		origins[strings.ToLower(o)] = true
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			h := w.Header()
+			h.Add("Vary", "Origin")
+			if !anyOrigin && !origins[strings.ToLower(origin)] {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if anyOrigin && !opts.AllowCredentials {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+			if opts.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			// preflight
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				h.Add("Vary", "Access-Control-Request-Method")
+				h.Add("Vary", "Access-Control-Request-Headers")
+				h.Set("Access-Control-Allow-Methods", methods)
+				if headers != "" {
+					h.Set("Access-Control-Allow-Headers", headers)
+				} else if rh := r.Header.Get("Access-Control-Request-Headers"); rh != "" {
+					h.Set("Access-Control-Allow-Headers", rh)
+				}
+				if opts.MaxAge > 0 {
+					h.Set("Access-Control-Max-Age", strconv.Itoa(opts.MaxAge))
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package violetear
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORS(t *testing.T) {
+	tt := []struct {
+		name          string
+		opts          CORSOptions
+		method        string
+		origin        string
+		requestMethod string
+		code          int
+		allowOrigin   string
+		allowMethods  string
+		allowHeaders  string
+		credentials   string
+		maxAge        string
+	}{
+		{"no origin", CORSOptions{AllowedOrigins: []string{"http://a.com"}}, "GET", "", "", 200, "", "", "", "", ""},
+		{"simple", CORSOptions{AllowedOrigins: []string{"http://a.com"}}, "GET", "http://a.com", "", 200, "http://a.com", "", "", "", ""},
+		{"simple case", CORSOptions{AllowedOrigins: []string{"http://A.com"}}, "GET", "http://a.com", "", 200, "http://a.com", "", "", "", ""},
+		{"disallowed", CORSOptions{AllowedOrigins: []string{"http://a.com"}}, "GET", "http://b.com", "", 200, "", "", "", "", ""},
+		{"disallowed preflight", CORSOptions{AllowedOrigins: []string{"http://a.com"}}, "OPTIONS", "http://b.com", "PUT", 204, "", "", "", "", ""},
+		{"any", CORSOptions{AllowedOrigins: []string{"*"}}, "GET", "http://b.com", "", 200, "*", "", "", "", ""},
+		{"any credentials", CORSOptions{AllowedOrigins: []string{"*"}, AllowCredentials: true}, "GET", "http://b.com", "", 200, "http://b.com", "", "", "true", ""},
+		{"preflight", CORSOptions{AllowedOrigins: []string{"http://a.com"}}, "OPTIONS", "http://a.com", "PUT", 204, "http://a.com", "GET, HEAD, POST", "X-Requested", "", ""},
+		{"preflight options", CORSOptions{
+			AllowedOrigins:   []string{"http://a.com"},
+			AllowedMethods:   []string{"get", "put"},
+			AllowedHeaders:   []string{"Content-Type", "Authorization"},
+			AllowCredentials: true,
+			MaxAge:           600,
+		}, "OPTIONS", "http://a.com", "PUT", 204, "http://a.com", "GET, PUT", "Content-Type, Authorization", "true", "600"},
+		{"options not preflight", CORSOptions{AllowedOrigins: []string{"http://a.com"}}, "OPTIONS", "http://a.com", "", 204, "http://a.com", "", "", "", ""},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			router := New()
+			router.Verbose = false
+			router.AutoOptions = true
+			router.Use(CORS(tc.opts))
+			router.HandleFunc("/test", func(w http.ResponseWriter, r *http.Request) {}, "GET,PUT")
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tc.method, "/test", nil)
+			if tc.origin != "" {
+				req.Header.Set("Origin", tc.origin)
+			}
+			if tc.requestMethod != "" {
+				req.Header.Set("Access-Control-Request-Method", tc.requestMethod)
+				req.Header.Set("Access-Control-Request-Headers", "X-Requested")
+			}
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, tc.code)
+			expect(t, w.Header().Get("Access-Control-Allow-Origin"), tc.allowOrigin)
+			expect(t, w.Header().Get("Access-Control-Allow-Methods"), tc.allowMethods)
+			expect(t, w.Header().Get("Access-Control-Allow-Headers"), tc.allowHeaders)
+			expect(t, w.Header().Get("Access-Control-Allow-Credentials"), tc.credentials)
+			expect(t, w.Header().Get("Access-Control-Max-Age"), tc.maxAge)
+			if tc.method == "OPTIONS" && tc.requestMethod == "" {
+				expect(t, w.Header().Get("Allow"), "GET, PUT, OPTIONS")
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package violetear
 
 import (
+	"container/list"
 	"errors"
 	"fmt"
 	"regexp"
@@ -14,13 +15,152 @@ func (d dynamicSet) Set(name, regex string) error {
 		return errors.New("dynamic route name must start with a colon ':'")
 	}
 
-	// fix regex
+	// anchor the regex to match the whole segment, "a|b" would match "ax"
+	// using "^a|b$", regexes starting with "^" are used as they are
 	if !strings.HasPrefix(regex, "^") {
-		regex = fmt.Sprintf("^%s$", regex)
+		regex = fmt.Sprintf("^(?:%s)$", regex)
 	}
 
-	r := regexp.MustCompile(regex)
+	r, err := regexp.Compile(regex)
+	if err != nil {
+		return fmt.Errorf("invalid regex for %s: %s", name, err)
+	}
 	d[name] = r
 
 	return nil
 }
+
+// inlineRegex returns the ":named" segment and the regex of an inline
+// constraint, "{id:[0-9]+}" is registered as ":id{[0-9]+}" so that routes
+// using the same name with different regexes don't share it
+func inlineRegex(p string) (string, string, bool) {
+	if len(p) < 5 || p[0] != '{' || p[len(p)-1] != '}' {
+		return "", "", false
+	}
+	i := strings.IndexByte(p, ':')
+	if i < 2 || i == len(p)-2 {
+		return "", "", false
+	}
+	name, regex := p[1:i], p[i+1:len(p)-1]
+	return ":" + name + "{" + regex + "}", regex, true
+}
+
+// inlineParts replaces the inline constraints of parts with their ":named"
+// segment
+func inlineParts(parts []string) {
+	for i, p := range parts {
+		if name, _, ok := inlineRegex(p); ok {
+			parts[i] = name
+		}
+	}
+}
+
+// paramKey returns the Params key of a ":named" segment removing the inline
+// regex, ":id{[0-9]+}" -> ":id"
+func paramKey(name string) string {
+	if i := strings.IndexByte(name, '{'); i != -1 {
+		return name[:i]
+	}
+	return name
+}
+
+type matcherSet map[string]func(string) bool
+
+func (m matcherSet) Set(name string, fn func(string) bool) error {
+	if !strings.HasPrefix(name, ":") {
+		return errors.New("dynamic route name must start with a colon ':'")
+	}
+	if fn == nil {
+		return errors.New("matcher function cannot be nil")
+	}
+	m[name] = fn
+	return nil
+}
+
+// subexpParams appends to pairs the named capture groups of rx matching value
+// using the key name.group, example: ":date.year"
+func subexpParams(pairs []string, name string, rx *regexp.Regexp, value string) []string {
+	names := rx.SubexpNames()
+	named := false
+	for _, n := range names {
+		if n != "" {
+			named = true
+			break
+		}
+	}
+	if !named {
+		return pairs
+	}
+	match := rx.FindStringSubmatch(value)
+	if match == nil {
+		return pairs
+	}
+	for i, n := range names {
+		if n != "" {
+			pairs = append(pairs, name+"."+n, match[i])
+		}
+	}
+	return pairs
+}
+
+// regexCache tracks the usage of the dynamic routes, when size is greater
+// than 0 the regular expressions not referenced by any route are evicted in
+// least recently added order until the dynamicSet fits the size. Regular
+// expressions referenced by a route are never evicted. Only AddRegex updates
+// the order, lookups on ServeHTTP use referenced regexes only.
+type regexCache struct {
+	size  int
+	refs  map[string]int
+	order *list.List
+	elems map[string]*list.Element
+}
+
+func newRegexCache() *regexCache {
+	return &regexCache{
+		refs:  map[string]int{},
+		order: list.New(),
+		elems: map[string]*list.Element{},
+	}
+}
+
+// add marks name as the most recently used
+func (c *regexCache) add(name string) {
+	if e, ok := c.elems[name]; ok {
+		c.order.MoveToFront(e)
+		return
+	}
+	c.elems[name] = c.order.PushFront(name)
+}
+
+// ref increments the number of routes using name
+func (c *regexCache) ref(name string) {
+	c.refs[name]++
+}
+
+// unref decrements the number of routes using name
+func (c *regexCache) unref(name string) {
+	if c.refs[name] > 1 {
+		c.refs[name]--
+		return
+	}
+	delete(c.refs, name)
+}
+
+// evict removes the least recently used and not referenced regular
+// expressions from d until it fits the cache size, the most recently used is
+// always kept so that it can be referenced by a route after being added.
+func (c *regexCache) evict(d dynamicSet) {
+	if c.size <= 0 {
+		return
+	}
+	for e := c.order.Back(); e != nil && e != c.order.Front() && len(d) > c.size; {
+		prev := e.Prev()
+		name := e.Value.(string)
+		if c.refs[name] == 0 {
+			delete(d, name)
+			delete(c.elems, name)
+			c.order.Remove(e)
+		}
+		e = prev
+	}
+}
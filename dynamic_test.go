@@ -4,7 +4,14 @@
 
 package violetear
 
-import "testing"
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
 
 func TestSetBadName(t *testing.T) {
 	s := make(dynamicSet)
@@ -14,6 +21,12 @@ func TestSetBadName(t *testing.T) {
 	}
 }
 
+func TestSetBadRegex(t *testing.T) {
+	s := make(dynamicSet)
+	expect(t, s.Set(":test", "[a-z") != nil, true)
+	expect(t, len(s), 0)
+}
+
 func TestSetOkName(t *testing.T) {
 	s := make(dynamicSet)
 	err := s.Set(":test", "test")
@@ -38,5 +51,304 @@ func TestFixRegex(t *testing.T) {
 	s := make(dynamicSet)
 	s.Set(":name", "az")
 	rx := s[":name"]
-	expect(t, rx.String(), "^az$")
+	expect(t, rx.String(), "^(?:az)$")
+}
+
+func TestRegexAnchored(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.AddRegex(":id", `\d+`)
+	router.AddRegex(":pet", `cat|dog`)
+	router.AddRegex(":prefix", `^\d+`)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(GetParam("id", r) + GetParam("pet", r) + GetParam("prefix", r)))
+	}
+	router.HandleFunc("/id/:id", handler)
+	router.HandleFunc("/pet/:pet", handler)
+	router.HandleFunc("/prefix/:prefix", handler)
+
+	tt := []struct {
+		path string
+		code int
+	}{
+		{"/id/12", 200},
+		{"/id/12abc", 404},
+		{"/id/abc12", 404},
+		{"/pet/cat", 200},
+		{"/pet/cats", 404},
+		{"/pet/hotdog", 404},
+		{"/prefix/12abc", 200},
+		{"/prefix/abc12", 404},
+	}
+	for _, tc := range tt {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		router.ServeHTTP(w, req)
+		expect(t, w.Code, tc.code)
+	}
+}
+
+func TestRegexCache(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.SetRegexCacheSize(2)
+	router.AddRegex(":id", `\d+`)
+	router.AddRegex(":word", `\w+`)
+	router.HandleFunc("/test/:id", func(w http.ResponseWriter, r *http.Request) {})
+	router.AddRegex(":a", `a`)
+	router.AddRegex(":b", `b`)
+	router.AddRegex(":c", `c`)
+
+	// :id is referenced, :word, :a and :b are evicted
+	expect(t, len(router.dynamicRoutes), 2)
+	_, ok := router.dynamicRoutes[":id"]
+	expect(t, ok, true)
+	_, ok = router.dynamicRoutes[":c"]
+	expect(t, ok, true)
+
+	// re-adding marks it as recently used
+	router.AddRegex(":d", `d`)
+	router.AddRegex(":d", `d`)
+	router.HandleFunc("/test/:d", func(w http.ResponseWriter, r *http.Request) {})
+	expect(t, len(router.dynamicRoutes), 2)
+	_, ok = router.dynamicRoutes[":d"]
+	expect(t, ok, true)
+
+	// all referenced, can't evict
+	router.AddRegex(":e", `e`)
+	router.HandleFunc("/test/:e", func(w http.ResponseWriter, r *http.Request) {})
+	expect(t, router.GetError(), nil)
+	expect(t, len(router.dynamicRoutes), 3)
+
+	// evicted regex can't be used
+	router.HandleFunc("/test/:word", func(w http.ResponseWriter, r *http.Request) {})
+	expect(t, router.GetError() != nil, true)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/test/123", nil)
+	router.ServeHTTP(w, req)
+	expect(t, w.Code, 200)
+}
+
+func TestRegexCacheUnbounded(t *testing.T) {
+	router := New()
+	for i := 0; i < 100; i++ {
+		router.AddRegex(fmt.Sprintf(":r%d", i), `\d+`)
+	}
+	expect(t, len(router.dynamicRoutes), 100)
+	router.SetRegexCacheSize(10)
+	expect(t, len(router.dynamicRoutes), 10)
+	_, ok := router.dynamicRoutes[":r99"]
+	expect(t, ok, true)
+	_, ok = router.dynamicRoutes[":r89"]
+	expect(t, ok, false)
+}
+
+func TestSubexpParams(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.AddRegex(":date", `(?P<year>\d{4})-(?P<month>\d{2})(-\d{2})?`)
+	router.AddRegex(":id", `(\d+)`)
+	router.HandleFunc("/archive/:date/:id", func(w http.ResponseWriter, r *http.Request) {
+		p := GetAllParams(r)
+		expect(t, p.Get("date"), "2019-07-31")
+		expect(t, p.Get("date.year"), "2019")
+		expect(t, GetParam("date.month", r), "07")
+		expect(t, p.Get("id"), "42")
+		expect(t, len(p), 4)
+	})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/archive/2019-07-31/42", nil)
+	router.ServeHTTP(w, req)
+	expect(t, w.Code, 200)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/archive/19-07/42", nil)
+	router.ServeHTTP(w, req)
+	expect(t, w.Code, 404)
+}
+
+func TestAddRegexes(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	errs := router.AddRegexes(map[string]string{
+		":id":    `\d+`,
+		":uuid":  `[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`,
+		":bad":   `[a-z`,
+		"name":   `\w+`,
+		":worse": `(a|b`,
+	})
+	expect(t, len(errs), 3)
+	expect(t, strings.Contains(errs[0].Error(), ":bad"), true)
+	expect(t, strings.Contains(errs[1].Error(), ":worse"), true)
+	expect(t, strings.Contains(errs[2].Error(), "colon"), true)
+	expect(t, len(router.dynamicRoutes), 2)
+
+	router.HandleFunc("/users/:id", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(GetParam("id", r)))
+	})
+	expect(t, router.GetError(), nil)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/users/42", nil)
+	router.ServeHTTP(w, req)
+	expect(t, w.Body.String(), "42")
+
+	expect(t, len(router.AddRegexes(nil)), 0)
+}
+
+func TestMatcherSet(t *testing.T) {
+	m := matcherSet{}
+	expect(t, m.Set("id", func(s string) bool { return true }) != nil, true)
+	expect(t, m.Set(":id", nil) != nil, true)
+	expect(t, m.Set(":id", func(s string) bool { return true }), nil)
+	expect(t, len(m), 1)
+}
+
+func TestAddMatcher(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	isInt := func(s string) bool {
+		_, err := strconv.Atoi(s)
+		return err == nil
+	}
+	expect(t, router.AddMatcher(":id", isInt), nil)
+	router.AddRegex(":slug", `[a-z-]+`)
+	// matcher wins over the regex
+	router.AddRegex(":num", `[a-z]+`)
+	router.AddMatcher(":num", isInt)
+	router.HandleFunc("/user/:id", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("user " + GetParam("id", r)))
+	})
+	router.HandleFunc("/post/:slug/:id", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(GetParam("slug", r) + " " + GetParam("id", r)))
+	})
+	router.HandleFunc("/num/:num", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("num " + GetParam("num", r)))
+	})
+	expect(t, router.GetError(), nil)
+
+	tt := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/user/42", 200, "user 42"},
+		{"/user/abc", 404, "404 page not found\n"},
+		{"/post/hello-world/7", 200, "hello-world 7"},
+		{"/post/hello-world/x", 404, "404 page not found\n"},
+		{"/num/3", 200, "num 3"},
+		{"/num/abc", 404, "404 page not found\n"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tc.path, nil)
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, tc.code)
+			expect(t, w.Body.String(), tc.body)
+		})
+	}
+
+	router.HandleNamed("user", "/user/:id", http.NotFoundHandler())
+	u, err := router.URL("user", map[string]string{"id": "3"})
+	expect(t, err, nil)
+	expect(t, u, "/user/3")
+	_, err = router.URL("user", map[string]string{"id": "x"})
+	expect(t, err != nil, true)
+}
+
+func TestMultipleRegexParams(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.AddRegex(":year", `\d{4}`)
+	router.AddRegex(":slug", `[a-z-]+`)
+	router.AddRegex(":id", `\d+`)
+	router.HandleFunc("/report/:year/:slug", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(GetParam("year", r) + " " + GetParam("slug", r)))
+	})
+	// :id also matches the year, the next dynamic node must be tried
+	router.HandleFunc("/item/:id/edit", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("edit " + GetParam("id", r)))
+	})
+	router.HandleFunc("/item/:year/archive", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("archive " + GetParam("year", r) + " " + GetParam("id", r)))
+	})
+
+	tt := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/report/2020/my-post", 200, "2020 my-post"},
+		{"/report/2020/My-Post", 404, "404 page not found\n"},
+		{"/report/20/my-post", 404, "404 page not found\n"},
+		{"/item/2020/edit", 200, "edit 2020"},
+		{"/item/2020/archive", 200, "archive 2020 "},
+		{"/item/1/archive", 404, "404 page not found\n"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tc.path, nil)
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, tc.code)
+			expect(t, w.Body.String(), tc.body)
+		})
+	}
+}
+
+func TestInlineRegex(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.AddRegex(":id", `[a-z]+`)
+	handler := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body + GetParam("id", r) + GetParam("id.year", r)))
+		}
+	}
+	router.HandleFunc("/user/{id:[0-9]+}", handler("user "))
+	router.HandleFunc("/user/{id:[0-9]+}/posts", handler("posts "))
+	router.HandleFunc(`/post/{id:(?P<year>\d{4})-\d{2}}`, handler("post "))
+	router.HandleFunc("/name/:id", handler("name "))
+	router.HandleNamed("user", "/users/{id:[0-9]+}", handler("users "))
+	expect(t, router.GetError(), nil)
+
+	tt := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/user/42", 200, "user 42"},
+		{"/user/42/posts", 200, "posts 42"},
+		{"/user/abc", 404, "404 page not found\n"},
+		{"/post/2020-01", 200, "post 2020-012020"},
+		{"/name/abc", 200, "name abc"},
+		{"/name/42", 404, "404 page not found\n"},
+	}
+	for _, tc := range tt {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		router.ServeHTTP(w, req)
+		expect(t, w.Code, tc.code)
+		expect(t, w.Body.String(), tc.body)
+	}
+
+	url, err := router.URL("user", map[string]string{"id": "7"})
+	expect(t, err, nil)
+	expect(t, url, "/users/7")
+	_, err = router.URL("user", map[string]string{"id": "x"})
+	expect(t, err != nil, true)
+
+	methods, err := router.AllowedMethods("/user/{id:[0-9]+}")
+	expect(t, err, nil)
+	expectDeepEqual(t, methods, []string{MethodAll})
+	expect(t, router.RemoveRoute("/user/{id:[0-9]+}"), nil)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/user/42", nil)
+	router.ServeHTTP(w, req)
+	expect(t, w.Code, 404)
+
+	// invalid regex
+	router.HandleFunc("/bad/{id:[0-9}", handler(""))
+	expect(t, router.GetError() != nil, true)
 }
@@ -0,0 +1,21 @@
+package violetear
+
+import "net/http"
+
+// ErrorHandlerFunc handler returning an error, see HandleError
+type ErrorHandlerFunc func(http.ResponseWriter, *http.Request) error
+
+// HandleError registers a handler returning an error, when it is not nil the
+// ErrorHandler of the router is called to write the response, if not set a
+// 500 with the error message is sent.
+func (r *Router) HandleError(path string, handler ErrorHandlerFunc, httpMethods ...string) *RouteHandle {
+	return r.HandleFunc(path, func(w http.ResponseWriter, req *http.Request) {
+		if err := handler(w, req); err != nil {
+			if r.ErrorHandler != nil {
+				r.ErrorHandler(w, req, err)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}, httpMethods...)
+}
@@ -0,0 +1,73 @@
+package violetear
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type badRequest struct {
+	msg string
+}
+
+func (e badRequest) Error() string {
+	return e.msg
+}
+
+func TestHandleError(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		code := http.StatusInternalServerError
+		if _, ok := err.(badRequest); ok {
+			code = http.StatusBadRequest
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+	}
+	router.AddRegex(":id", `\w+`)
+	router.HandleError("/users/:id", func(w http.ResponseWriter, r *http.Request) error {
+		switch GetParam("id", r) {
+		case "bad":
+			return badRequest{"invalid id"}
+		case "fail":
+			return errors.New("boom")
+		}
+		w.Write([]byte("ok"))
+		return nil
+	}, "GET")
+	expect(t, router.GetError(), nil)
+
+	tt := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/users/1", 200, "ok"},
+		{"/users/bad", 400, "{\"error\":\"invalid id\"}\n"},
+		{"/users/fail", 500, "{\"error\":\"boom\"}\n"},
+	}
+	for _, tc := range tt {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		router.ServeHTTP(w, req)
+		expect(t, w.Code, tc.code)
+		expect(t, w.Body.String(), tc.body)
+	}
+}
+
+func TestHandleErrorDefault(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.HandleError("/", func(w http.ResponseWriter, r *http.Request) error {
+		return errors.New("boom")
+	})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	router.ServeHTTP(w, req)
+	expect(t, w.Code, 500)
+	expect(t, w.Body.String(), "boom\n")
+}
@@ -0,0 +1,135 @@
+package violetear
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// etagMaxSize responses bigger than this are streamed without ETag
+const etagMaxSize = 1 << 20
+
+// etagResponseWriter buffers the body to compute the ETag, it switches to
+// stream the response if the body is bigger than etagMaxSize or on Flush.
+type etagResponseWriter struct {
+	http.ResponseWriter
+	buf         bytes.Buffer
+	status      int
+	passthrough bool
+}
+
+// WriteHeader keeps the status code until the body is complete
+func (w *etagResponseWriter) WriteHeader(statusCode int) {
+	if w.passthrough {
+		w.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	if w.status == 0 {
+		w.status = statusCode
+	}
+}
+
+// Write buffers the data until etagMaxSize
+func (w *etagResponseWriter) Write(data []byte) (int, error) {
+	if !w.passthrough && w.buf.Len()+len(data) > etagMaxSize {
+		if err := w.stream(); err != nil {
+			return 0, err
+		}
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.buf.Write(data)
+}
+
+// Flush streams the response
+func (w *etagResponseWriter) Flush() {
+	w.stream()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// stream sends the status and the buffered data, next writes are not
+// buffered
+func (w *etagResponseWriter) stream() error {
+	if w.passthrough {
+		return nil
+	}
+	w.passthrough = true
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if w.buf.Len() > 0 {
+		_, err := w.ResponseWriter.Write(w.buf.Bytes())
+		w.buf.Reset()
+		return err
+	}
+	return nil
+}
+
+// close sets the ETag and sends the response or 304 if If-None-Match
+// matches
+func (w *etagResponseWriter) close(r *http.Request) {
+	if w.passthrough {
+		return
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.status == http.StatusOK {
+		h := w.Header()
+		etag := h.Get("ETag")
+		if etag == "" {
+			sum := sha256.Sum256(w.buf.Bytes())
+			etag = `"` + hex.EncodeToString(sum[:]) + `"`
+			h.Set("ETag", etag)
+		}
+		if etagMatch(r.Header.Get("If-None-Match"), etag) {
+			h.Del("Content-Type")
+			h.Del("Content-Length")
+			w.ResponseWriter.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	w.stream()
+}
+
+// etagMatch returns true if etag is in the If-None-Match header using the
+// weak comparison
+func etagMatch(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimSpace(v)
+		if v == "*" || strings.TrimPrefix(v, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// ETag returns a middleware setting a strong ETag, the sha256 of the body,
+// on 200 responses to GET and HEAD requests and responding 304 when the
+// If-None-Match header matches. A handler setting its own ETag header is
+// respected. Responses bigger than 1MB or flushed by the handler are
+// streamed without ETag.
+func ETag() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			// not deferred, the buffered body of a handler that panics is
+			// discarded so the panic response is sent instead
+			ew := &etagResponseWriter{ResponseWriter: w}
+			next.ServeHTTP(ew, r)
+			ew.close(r)
+		})
+	}
+}
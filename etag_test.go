@@ -0,0 +1,153 @@
+package violetear
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestETag(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.LogRequests = true
+	router.Logger = func(w *ResponseWriter, r *http.Request) {}
+	router.Use(ETag())
+	router.HandleFunc("/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"hello":`))
+		w.Write([]byte(`"world"}`))
+	})
+	router.HandleFunc("/custom", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("custom"))
+	})
+	router.HandleFunc("/created", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("created"))
+	})
+	router.HandleFunc("/big", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(bytes.Repeat([]byte("a"), etagMaxSize))
+		w.Write([]byte("b"))
+	})
+	router.HandleFunc("/stream", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("chunk"))
+		w.(http.Flusher).Flush()
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/json", nil)
+	router.ServeHTTP(w, req)
+	expect(t, w.Code, 200)
+	expect(t, w.Body.String(), `{"hello":"world"}`)
+	etag := w.Header().Get("ETag")
+	expect(t, len(etag), 66)
+
+	tt := []struct {
+		method      string
+		path        string
+		ifNoneMatch string
+		code        int
+		body        string
+		etag        string
+	}{
+		{"GET", "/json", etag, 304, "", etag},
+		{"GET", "/json", `"x", W/` + etag, 304, "", etag},
+		{"GET", "/json", "*", 304, "", etag},
+		{"GET", "/json", `"x"`, 200, `{"hello":"world"}`, etag},
+		{"HEAD", "/json", etag, 304, "", etag},
+		{"POST", "/json", etag, 200, `{"hello":"world"}`, ""},
+		{"GET", "/custom", `"v1"`, 304, "", `"v1"`},
+		{"GET", "/custom", `"v2"`, 200, "custom", `"v1"`},
+		{"GET", "/created", "*", 201, "created", ""},
+		{"GET", "/stream", "*", 200, "chunk", ""},
+	}
+	for _, tc := range tt {
+		t.Run(tc.method+tc.path+tc.ifNoneMatch, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tc.method, tc.path, nil)
+			req.Header.Set("If-None-Match", tc.ifNoneMatch)
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, tc.code)
+			expect(t, w.Body.String(), tc.body)
+			expect(t, w.Header().Get("ETag"), tc.etag)
+		})
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/big", nil)
+	router.ServeHTTP(w, req)
+	expect(t, w.Code, 200)
+	expect(t, w.Body.Len(), etagMaxSize+1)
+	expect(t, w.Header().Get("ETag"), "")
+}
+
+func TestEtagMatch(t *testing.T) {
+	expect(t, etagMatch("", `"a"`), false)
+	expect(t, etagMatch(`"a"`, `"a"`), true)
+	expect(t, etagMatch(`W/"a"`, `"a"`), true)
+	expect(t, etagMatch(`"b", "a"`, `"a"`), true)
+	expect(t, etagMatch(`"b"`, `"a"`), false)
+	expect(t, etagMatch("*", `"a"`), true)
+}
+
+func TestETagAutoHead(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.AutoHead = true
+	router.Use(ETag())
+	router.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}, "GET")
+
+	get := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/hello", nil)
+	router.ServeHTTP(get, req)
+	expect(t, get.Code, 200)
+	etag := get.Header().Get("ETag")
+	expect(t, len(etag), 66)
+
+	head := httptest.NewRecorder()
+	req, _ = http.NewRequest("HEAD", "/hello", nil)
+	router.ServeHTTP(head, req)
+	expect(t, head.Code, 200)
+	expect(t, head.Body.String(), "")
+	expect(t, head.Header().Get("ETag"), etag)
+	expect(t, head.Header().Get("Content-Length"), "5")
+
+	head = httptest.NewRecorder()
+	req, _ = http.NewRequest("HEAD", "/hello", nil)
+	req.Header.Set("If-None-Match", etag)
+	router.ServeHTTP(head, req)
+	expect(t, head.Code, 304)
+	expect(t, head.Header().Get("Content-Length"), "")
+}
+
+func TestETagPanic(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	var status int
+	router := New()
+	router.Verbose = false
+	router.LogRequests = true
+	router.LogHandler = func(e LogEntry) {
+		status = e.Status
+	}
+	router.Use(ETag())
+	router.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		panic("etag")
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/panic", nil)
+	router.ServeHTTP(w, req)
+	expect(t, w.Code, 500)
+	expect(t, w.Body.String(), "Internal Server Error\n")
+	expect(t, w.Header().Get("ETag"), "")
+	expect(t, status, 500)
+}
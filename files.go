@@ -0,0 +1,35 @@
+package violetear
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// ServeFiles serves files from root under pathPrefix, example:
+//
+//	router.ServeFiles("/static/", http.Dir("/var/www"))
+//
+// A request to /static/css/app.css serves /var/www/css/app.css, missing files
+// are handled by the NotFoundHandler. The path is cleaned before opening the
+// file so it can't go above root.
+func (r *Router) ServeFiles(pathPrefix string, root http.FileSystem) *RouteHandle {
+	prefix := joinPath("/", pathPrefix)
+	fileServer := http.FileServer(root)
+	return r.Handle(joinPath(prefix, "*"), http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		name := path.Clean("/" + strings.TrimPrefix(req.URL.Path, prefix))
+		f, err := root.Open(name)
+		if err != nil {
+			r.notFound().ServeHTTP(w, req)
+			return
+		}
+		f.Close()
+		r2 := new(http.Request)
+		*r2 = *req
+		r2.URL = new(url.URL)
+		*r2.URL = *req.URL
+		r2.URL.Path = name
+		fileServer.ServeHTTP(w, r2)
+	}), "GET, HEAD")
+}
@@ -0,0 +1,63 @@
+package violetear
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestServeFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "violetear")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	root := filepath.Join(dir, "root")
+	if err := os.MkdirAll(filepath.Join(root, "css"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "css", "app.css"), []byte("body{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "hello.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	router := New()
+	router.Verbose = false
+	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "custom 404", http.StatusNotFound)
+	})
+	router.ServeFiles("/static", http.Dir(root))
+	expect(t, router.GetError(), nil)
+
+	tt := []struct {
+		name   string
+		method string
+		path   string
+		code   int
+		body   string
+	}{
+		{"file", "GET", "/static/hello.txt", 200, "hello"},
+		{"nested file", "GET", "/static/css/app.css", 200, "body{}"},
+		{"missing", "GET", "/static/missing.txt", 404, "custom 404\n"},
+		{"traversal", "GET", "/static/../secret.txt", 404, "custom 404\n"},
+		{"traversal nested", "GET", "/static/css/../../secret.txt", 404, "custom 404\n"},
+		{"method", "POST", "/static/hello.txt", 405, "Method Not Allowed\n"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tc.method, tc.path, nil)
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, tc.code)
+			expect(t, w.Body.String(), tc.body)
+		})
+	}
+}
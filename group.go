@@ -0,0 +1,81 @@
+package violetear
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Group of routes sharing a common prefix and middleware
+type Group struct {
+	router     *Router
+	prefix     string
+	middleware []func(http.Handler) http.Handler
+}
+
+// Group returns a new group of routes, every path registered through the
+// group is prefixed with prefix.
+func (r *Router) Group(prefix string) *Group {
+	return &Group{
+		router: r,
+		prefix: joinPath("/", prefix),
+	}
+}
+
+// Group returns a nested group, the prefix is appended to the current one and
+// the middleware registered so far on the parent is inherited.
+func (g *Group) Group(prefix string) *Group {
+	return &Group{
+		router:     g.router,
+		prefix:     joinPath(g.prefix, prefix),
+		middleware: append([]func(http.Handler) http.Handler(nil), g.middleware...),
+	}
+}
+
+// Use appends middleware to the group, it runs after the Router global
+// middleware and before the route handler.
+func (g *Group) Use(mw ...func(http.Handler) http.Handler) {
+	g.middleware = append(g.middleware, mw...)
+}
+
+// NotFound sets the handler called when no route matches a path under the
+// group prefix, the longest prefix wins and paths outside of any group use
+// the Router NotFoundHandler.
+func (g *Group) NotFound(handler http.Handler) {
+	prefix := g.prefix
+	if i := strings.Index(prefix, "#"); i != -1 {
+		prefix = prefix[:i]
+	}
+	r := g.router
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.notFoundHandlers = append(r.notFoundHandlers, prefixHandler{
+		parts:   r.splitPath(prefix),
+		handler: handler,
+	})
+}
+
+// Handle registers the handler for the prefixed path
+func (g *Group) Handle(path string, handler http.Handler, httpMethods ...string) *RouteHandle {
+	return g.router.HandleWithMiddleware(joinPath(g.prefix, path), handler, g.middleware, httpMethods...)
+}
+
+// HandleFunc registers the handler function for the prefixed path
+func (g *Group) HandleFunc(path string, handler http.HandlerFunc, httpMethods ...string) *RouteHandle {
+	return g.Handle(path, handler, httpMethods...)
+}
+
+// joinPath joins prefix and path using a single slash
+func joinPath(prefix, path string) string {
+	prefix = strings.TrimRight(prefix, "/")
+	path = strings.TrimLeft(path, "/")
+	if path == "" {
+		if prefix == "" {
+			return "/"
+		}
+		return prefix
+	}
+	if strings.HasPrefix(path, "#") {
+		return prefix + path
+	}
+	return prefix + "/" + path
+}
@@ -0,0 +1,141 @@
+package violetear
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestJoinPath(t *testing.T) {
+	tt := []struct {
+		prefix string
+		path   string
+		out    string
+	}{
+		{"/", "", "/"},
+		{"", "/", "/"},
+		{"/api", "/users", "/api/users"},
+		{"/api/", "/users", "/api/users"},
+		{"api", "users/", "/api/users/"},
+		{"/api", "", "/api"},
+		{"/api", "/", "/api"},
+		{"/api", "#v2", "/api#v2"},
+		{"/api", "/users#v2", "/api/users#v2"},
+	}
+	for _, tc := range tt {
+		expect(t, joinPath(joinPath("/", tc.prefix), tc.path), tc.out)
+	}
+}
+
+func TestGroup(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.AddRegex(":id", `\d+`)
+
+	var order []string
+	mw := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	handler := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		}
+	}
+
+	router.Use(mw("global"))
+	api := router.Group("/api")
+	api.Use(mw("api"))
+	api.HandleFunc("/users", handler("users"), "GET")
+	v1 := api.Group("v1/")
+	v1.Use(mw("v1"))
+	v1.HandleFunc("/users/:id", handler("v1 user"), "GET")
+	api.HandleFunc("/status", handler("status"), "GET")
+	expect(t, router.GetError(), nil)
+
+	tt := []struct {
+		path  string
+		code  int
+		body  string
+		order []string
+	}{
+		{"/api/users", 200, "users", []string{"global", "api"}},
+		{"/api/v1/users/1", 200, "v1 user", []string{"global", "api", "v1"}},
+		{"/api/status", 200, "status", []string{"global", "api"}},
+		{"/users", 404, "404 page not found\n", []string{"global"}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.path, func(t *testing.T) {
+			order = nil
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tc.path, nil)
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, tc.code)
+			expect(t, w.Body.String(), tc.body)
+			expectDeepEqual(t, order, tc.order)
+		})
+	}
+}
+
+func TestGroupNotFound(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.AddRegex(":id", `\d+`)
+	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "global", http.StatusNotFound)
+	})
+	api := router.Group("/api")
+	api.NotFound(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"not found"}`))
+	}))
+	api.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("users"))
+	})
+	user := api.Group("/users/:id")
+	user.NotFound(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "user", http.StatusNotFound)
+	}))
+	user.HandleFunc("/profile", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("profile"))
+	})
+
+	tt := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/api/users", 200, "users"},
+		{"/api/missing", 404, `{"error":"not found"}`},
+		{"/api", 404, `{"error":"not found"}`},
+		{"/api/users/1/profile", 200, "profile"},
+		{"/api/users/1/missing", 404, "user\n"},
+		{"/api/users/x/missing", 404, `{"error":"not found"}`},
+		{"/missing", 404, "global\n"},
+		{"/apis", 404, "global\n"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tc.path, nil)
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, tc.code)
+			expect(t, w.Body.String(), tc.body)
+		})
+	}
+
+	root := New()
+	root.Verbose = false
+	root.Group("/").NotFound(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "root", http.StatusNotFound)
+	}))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/missing", nil)
+	root.ServeHTTP(w, req)
+	expect(t, w.Body.String(), "root\n")
+}
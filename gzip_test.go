@@ -0,0 +1,152 @@
+package violetear
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAcceptsGzip(t *testing.T) {
+	tt := []struct {
+		header string
+		out    bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"deflate, gzip", true},
+		{"gzip;q=0.8, br", true},
+		{"GZIP", true},
+		{"gzip; q=0", false},
+		{"br", false},
+		{"x-gzip", false},
+	}
+	for _, tc := range tt {
+		expect(t, acceptsGzip(tc.header), tc.out)
+	}
+}
+
+func TestCompressible(t *testing.T) {
+	tt := []struct {
+		contentType string
+		out         bool
+	}{
+		{"", true},
+		{"application/json", true},
+		{"text/html; charset=utf-8", true},
+		{"image/svg+xml", true},
+		{"image/png", false},
+		{"video/mp4", false},
+		{"application/zip", false},
+		{"application/gzip", false},
+	}
+	for _, tc := range tt {
+		expect(t, compressible(tc.contentType), tc.out)
+	}
+}
+
+func TestGzip(t *testing.T) {
+	body := strings.Repeat(`{"hello":"world"}`, 100)
+	tt := []struct {
+		name           string
+		acceptEncoding string
+		contentType    string
+		code           int
+		gzip           bool
+	}{
+		{"gzip", "gzip, deflate", "application/json", 200, true},
+		{"gzip sniff", "gzip", "", 201, true},
+		{"no gzip", "", "application/json", 200, false},
+		{"compressed type", "gzip", "image/png", 200, false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			router := New()
+			router.Verbose = false
+			router.LogRequests = true
+			router.Logger = func(w *ResponseWriter, r *http.Request) {
+				expect(t, w.Status(), tc.code)
+				if tc.gzip {
+					expect(t, w.Size() < len(body), true)
+				} else {
+					expect(t, w.Size(), len(body))
+				}
+			}
+			router.Use(Gzip(gzip.BestSpeed))
+			router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+				if tc.contentType != "" {
+					w.Header().Set("Content-Type", tc.contentType)
+				}
+				w.WriteHeader(tc.code)
+				w.Write([]byte(body[:10]))
+				w.Write([]byte(body[10:]))
+			})
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/", nil)
+			if tc.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			}
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, tc.code)
+			expect(t, w.Header().Get("Vary"), "Accept-Encoding")
+			if !tc.gzip {
+				expect(t, w.Header().Get("Content-Encoding"), "")
+				expect(t, w.Body.String(), body)
+				return
+			}
+			expect(t, w.Header().Get("Content-Encoding"), "gzip")
+			expect(t, w.Header().Get("Content-Type") != "", true)
+			gz, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := ioutil.ReadAll(gz)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expect(t, string(b), body)
+		})
+	}
+}
+
+func TestGzipNoBody(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.Use(Gzip(42))
+	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	router.HandleFunc("/empty", func(w http.ResponseWriter, r *http.Request) {})
+	for _, path := range []string{"/", "/empty"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		router.ServeHTTP(w, req)
+		expect(t, w.Header().Get("Content-Encoding"), "")
+		expect(t, w.Body.Len(), 0)
+	}
+}
+
+func TestGzipFlushFirst(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.Use(Gzip(42))
+	router.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusCreated)
+		w.(http.Flusher).Flush()
+		w.Write([]byte("data: hello\n\n"))
+		w.(http.Flusher).Flush()
+	})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/events", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	router.ServeHTTP(w, req)
+	expect(t, w.Code, http.StatusCreated)
+	expect(t, w.Flushed, true)
+	// the headers were sent before any data, the body is not compressed
+	expect(t, w.Header().Get("Content-Encoding"), "")
+	expect(t, w.Body.String(), "data: hello\n\n")
+}
@@ -0,0 +1,37 @@
+package violetear
+
+import "net/http"
+
+// HealthCheck registers a GET handler for path returning 200 "OK" when check
+// returns nil and 503 with the error message otherwise. The requests are not
+// logged unless LogHealthCheck is true.
+func (r *Router) HealthCheck(path string, check func() error) *RouteHandle {
+	trie := r.HandleFunc(path, func(w http.ResponseWriter, req *http.Request) {
+		if err := check(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("OK"))
+	}, http.MethodGet)
+	if trie == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.healthChecks == nil {
+		r.healthChecks = map[string]bool{}
+	}
+	r.healthChecks[trie.pattern] = true
+	return trie
+}
+
+// isHealthCheck returns true if pattern was registered with HealthCheck
+func (r *Router) isHealthCheck(pattern string) bool {
+	if pattern == "" {
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.healthChecks[pattern]
+}
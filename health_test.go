@@ -0,0 +1,65 @@
+package violetear
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealthCheck(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	var err error
+	router.HealthCheck("/health", func() error {
+		return err
+	})
+	expect(t, router.GetError(), nil)
+
+	tt := []struct {
+		method string
+		err    error
+		code   int
+		body   string
+	}{
+		{"GET", nil, 200, "OK"},
+		{"GET", errors.New("database down"), 503, "database down\n"},
+		{"POST", nil, 405, "Method Not Allowed\n"},
+	}
+	for _, tc := range tt {
+		err = tc.err
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(tc.method, "/health", nil)
+		router.ServeHTTP(w, req)
+		expect(t, w.Code, tc.code)
+		expect(t, w.Body.String(), tc.body)
+	}
+}
+
+func TestHealthCheckLog(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.LogRequests = true
+	var entries []LogEntry
+	router.LogHandler = func(e LogEntry) {
+		entries = append(entries, e)
+	}
+	router.HealthCheck("/health", func() error { return nil })
+	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	for _, path := range []string{"/health", "/"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(w, req)
+	}
+	expect(t, len(entries), 1)
+	expect(t, entries[0].Path, "/")
+
+	router.LogHealthCheck = true
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/health", nil)
+	router.ServeHTTP(w, req)
+	expect(t, len(entries), 2)
+	expect(t, entries[1].Path, "/health")
+	expect(t, entries[1].Status, 200)
+}
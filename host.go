@@ -0,0 +1,114 @@
+package violetear
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Host returns the Router used for requests to hostname, wildcards like
+// "*.example.com" match any subdomain of example.com. The host Router shares
+// the dynamic routes (AddRegex, AddMatcher) with its parent but has its own
+// routes, requests not matching any host use the parent routes. The request
+// is handled by the parent ServeHTTP: the RequestID, panic recovery, logging,
+// metrics, context values, versioning and the global middleware of the parent
+// apply to every host, the host Router only contributes its routes, the
+// route matching settings like NotFoundHandler or AutoHead and its own global
+// middleware, which runs after the parent's.
+func (r *Router) Host(hostname string) *Router {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hostname = strings.ToLower(stripPort(hostname))
+	if r.hosts == nil {
+		r.hosts = map[string]*Router{}
+	}
+	if router, ok := r.hosts[hostname]; ok {
+		return router
+	}
+	router := New()
+	router.mu = r.mu
+	router.dynamicRoutes = r.dynamicRoutes
+	router.matchers = r.matchers
+	router.regexCache = r.regexCache
+	router.Verbose = r.Verbose
+	r.hosts[hostname] = router
+	return router
+}
+
+// HandleHosts registers the handler for path on the Router of every host,
+// see Host. The hostnames are validated before registering any route, a
+// wildcard is only allowed as the first label: "*.example.com".
+func (r *Router) HandleHosts(hosts []string, path string, handler http.Handler, httpMethods ...string) error {
+	if len(hosts) == 0 {
+		return fmt.Errorf("no hosts for path %q", path)
+	}
+	for _, host := range hosts {
+		if !validHost(strings.TrimPrefix(stripPort(host), "*.")) {
+			return fmt.Errorf("invalid host %q", host)
+		}
+	}
+	for _, host := range hosts {
+		router := r.Host(host)
+		if router.Handle(path, handler, httpMethods...) == nil {
+			return router.GetError()
+		}
+	}
+	return nil
+}
+
+// validHost returns true if host is a valid hostname or IP address
+func validHost(host string) bool {
+	if net.ParseIP(host) != nil {
+		return true
+	}
+	if host == "" || len(host) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(host, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// matchHost returns the Router registered for host, exact matches are
+// preferred over wildcards and the longest wildcard wins.
+func (r *Router) matchHost(host string) (*Router, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.hosts) == 0 {
+		return nil, false
+	}
+	host = strings.ToLower(stripPort(host))
+	if router, ok := r.hosts[host]; ok {
+		return router, true
+	}
+	var (
+		match  *Router
+		suffix string
+	)
+	for h, router := range r.hosts {
+		if !strings.HasPrefix(h, "*.") {
+			continue
+		}
+		if strings.HasSuffix(host, h[1:]) && len(h) > len(suffix) {
+			match, suffix = router, h
+		}
+	}
+	return match, match != nil
+}
+
+// stripPort removes the port from host if any
+func stripPort(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
@@ -0,0 +1,199 @@
+package violetear
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStripPort(t *testing.T) {
+	tt := []struct {
+		in  string
+		out string
+	}{
+		{"example.com", "example.com"},
+		{"example.com:8080", "example.com"},
+		{"127.0.0.1:80", "127.0.0.1"},
+		{"[::1]:80", "::1"},
+		{"", ""},
+	}
+	for _, tc := range tt {
+		expect(t, stripPort(tc.in), tc.out)
+	}
+}
+
+func TestHost(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.AddRegex(":id", `\d+`)
+	handler := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body + GetParam("id", r)))
+		}
+	}
+	router.HandleFunc("/", handler("default"))
+	router.Host("api.example.com").HandleFunc("/", handler("api"))
+	router.Host("api.example.com").HandleFunc("/user/:id", handler("user "))
+	router.Host("*.example.com").HandleFunc("/", handler("wildcard"))
+	router.Host("*.eu.example.com").HandleFunc("/", handler("eu"))
+	expect(t, router.Host("API.example.com:80"), router.Host("api.example.com"))
+
+	tt := []struct {
+		name string
+		host string
+		path string
+		code int
+		body string
+	}{
+		{"exact", "api.example.com", "/", 200, "api"},
+		{"exact with port", "api.example.com:8080", "/", 200, "api"},
+		{"exact case", "API.Example.com", "/", 200, "api"},
+		{"exact shared regex", "api.example.com", "/user/3", 200, "user 3"},
+		{"exact not found", "api.example.com", "/none", 404, "404 page not found\n"},
+		{"wildcard", "www.example.com", "/", 200, "wildcard"},
+		{"longest wildcard", "fr.eu.example.com", "/", 200, "eu"},
+		{"apex", "example.com", "/", 200, "default"},
+		{"default", "other.org", "/", 200, "default"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tc.path, nil)
+			req.Host = tc.host
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, tc.code)
+			expect(t, w.Body.String(), tc.body)
+		})
+	}
+}
+
+func TestHandleHosts(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("default"))
+	})
+	err := router.HandleHosts([]string{"example.com", "www.example.com:8080", "*.example.org"}, "/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("shared"))
+	}), "GET")
+	expect(t, err, nil)
+
+	tt := []struct {
+		host string
+		body string
+	}{
+		{"example.com", "shared"},
+		{"www.example.com", "shared"},
+		{"api.example.org", "shared"},
+		{"example.org", "default"},
+		{"api.example.com", "default"},
+		{"example.net", "default"},
+	}
+	for _, tc := range tt {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "http://"+tc.host+"/", nil)
+		router.ServeHTTP(w, req)
+		expect(t, w.Code, 200)
+		expect(t, w.Body.String(), tc.body)
+	}
+}
+
+func TestHandleHostsInvalid(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	for _, hosts := range [][]string{
+		nil,
+		{""},
+		{"example.com", "bad host.com"},
+		{"a..example.com"},
+		{"-example.com"},
+		{"api.*.example.com"},
+		{"*"},
+	} {
+		router := New()
+		router.Verbose = false
+		expect(t, router.HandleHosts(hosts, "/", handler) != nil, true)
+		expect(t, len(router.hosts), 0)
+	}
+
+	router := New()
+	router.Verbose = false
+	expect(t, router.HandleHosts([]string{"127.0.0.1", "[::1]:80"}, "/", handler), nil)
+	expect(t, router.HandleHosts([]string{"example.com"}, "/:missing", handler) != nil, true)
+}
+
+func TestHostParentSettings(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.RequestID = "Request-ID"
+	var order []string
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			order = append(order, "parent")
+			next.ServeHTTP(w, r)
+		})
+	})
+	var logged, measured string
+	router.LogRequests = true
+	router.Logger = func(w *ResponseWriter, r *http.Request) {
+		logged = fmt.Sprintf("%d %s", w.Status(), GetPattern(r))
+	}
+	router.MetricsHook = func(pattern, method string, status int, d time.Duration) {
+		measured = fmt.Sprintf("%d %s", status, pattern)
+	}
+	api := router.Host("api.example.com")
+	api.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			order = append(order, "host")
+			next.ServeHTTP(w, r)
+		})
+	})
+	api.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(RequestID(r)))
+	})
+	api.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("host")
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://api.example.com/user", nil)
+	req.Header.Set("Request-ID", "abc")
+	router.ServeHTTP(w, req)
+	expect(t, w.Code, 200)
+	expect(t, w.Body.String(), "abc")
+	expect(t, w.Header().Get("Request-ID"), "abc")
+	expectDeepEqual(t, order, []string{"parent", "host"})
+	expect(t, logged, "200 /user")
+	expect(t, measured, "200 /user")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "http://api.example.com/panic", nil)
+	router.ServeHTTP(w, req)
+	expect(t, w.Code, 500)
+	expect(t, logged, "500 /panic")
+}
+
+func TestHostConcurrentUse(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.Host("api.example.com").HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			router.Use(func(h http.Handler) http.Handler { return h })
+		}()
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "http://api.example.com/", nil)
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, 200)
+		}()
+	}
+	wg.Wait()
+}
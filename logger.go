@@ -1,10 +1,32 @@
 package violetear
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
 	"log"
+	"math/rand"
 	"net/http"
+	"strconv"
+	"time"
 )
 
+// LogEntry request details passed to the Router LogHandler
+type LogEntry struct {
+	Method     string
+	Path       string
+	Status     int
+	Bytes      int
+	Duration   time.Duration
+	RequestID  string
+	RemoteAddr string
+	// Panic message of the recovered panic, empty if the handler didn't
+	// panic
+	Panic string
+	// Stack trace of the recovered panic if the Router LogPanicStack is true
+	Stack string
+}
+
 // logger log values separated by space
 func logger(ww *ResponseWriter, r *http.Request) {
 	log.Printf("%s [%s] %d %d %s %s",
@@ -14,4 +36,134 @@ func logger(ww *ResponseWriter, r *http.Request) {
 		ww.Size(),
 		ww.RequestTime(),
 		ww.RequestID())
+	if ww.Stack() != "" {
+		log.Printf("panic stack:\n%s", ww.Stack())
+	}
+}
+
+// CommonLogFormat returns a Logger writing to out in the Apache Common Log
+// Format, example:
+//
+//	router.Logger = violetear.CommonLogFormat(os.Stdout)
+//
+//	127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET /index.html HTTP/1.1" 200 2326
+func CommonLogFormat(out io.Writer) func(*ResponseWriter, *http.Request) {
+	return func(ww *ResponseWriter, r *http.Request) {
+		fmt.Fprintf(out, "%s\n", commonLogLine(ww, r))
+	}
+}
+
+// CombinedLogFormat returns a Logger writing to out in the Apache Combined
+// Log Format, the Common Log Format followed by the Referer and User-Agent.
+func CombinedLogFormat(out io.Writer) func(*ResponseWriter, *http.Request) {
+	return func(ww *ResponseWriter, r *http.Request) {
+		fmt.Fprintf(out, "%s %q %q\n", commonLogLine(ww, r), orDash(r.Referer()), orDash(r.UserAgent()))
+	}
+}
+
+// jsonLogEntry JSON object written by JSONLogger
+type jsonLogEntry struct {
+	Method     string  `json:"method"`
+	Path       string  `json:"path"`
+	Status     int     `json:"status"`
+	Bytes      int     `json:"bytes"`
+	DurationMS float64 `json:"duration_ms"`
+	RequestID  string  `json:"request_id,omitempty"`
+	RemoteAddr string  `json:"remote_addr"`
+	UserAgent  string  `json:"user_agent,omitempty"`
+}
+
+// JSONLogger returns a Logger writing to out one JSON object per request,
+// example:
+//
+//	router.Logger = violetear.JSONLogger(os.Stdout)
+//
+//	{"method":"GET","path":"/hello","status":200,"bytes":5,"duration_ms":0.012,"remote_addr":"127.0.0.1:1234"}
+func JSONLogger(out io.Writer) func(*ResponseWriter, *http.Request) {
+	return func(ww *ResponseWriter, r *http.Request) {
+		enc := json.NewEncoder(out)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(jsonLogEntry{
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Status:     ww.Status(),
+			Bytes:      ww.Size(),
+			DurationMS: float64(ww.Duration()) / float64(time.Millisecond),
+			RequestID:  ww.RequestID(),
+			RemoteAddr: r.RemoteAddr,
+			UserAgent:  r.UserAgent(),
+		}); err != nil {
+			log.Printf("JSONLogger: %s", err)
+		}
+	}
+}
+
+// commonLogLine returns the request in the Common Log Format
+func commonLogLine(ww *ResponseWriter, r *http.Request) string {
+	user := "-"
+	if r.URL.User != nil && r.URL.User.Username() != "" {
+		user = r.URL.User.Username()
+	} else if u, _, ok := r.BasicAuth(); ok && u != "" {
+		user = u
+	}
+	uri := r.RequestURI
+	if uri == "" {
+		uri = r.URL.RequestURI()
+	}
+	size := "-"
+	if ww.Size() > 0 {
+		size = strconv.Itoa(ww.Size())
+	}
+	return fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %s",
+		stripPort(r.RemoteAddr),
+		user,
+		ww.start.Format("02/Jan/2006:15:04:05 -0700"),
+		r.Method,
+		uri,
+		r.Proto,
+		ww.Status(),
+		size)
+}
+
+// orDash returns "-" if s is empty
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// log calls the LogHandler if set otherwise the Logger, requests without
+// errors are skipped if LogOnlyErrors is true, otherwise the requests not
+// panicking are sampled using the LogSampleRate
+func (r *Router) log(ww *ResponseWriter, req *http.Request) {
+	ww.duration = time.Since(ww.start)
+	if r.LogOnlyErrors {
+		if ww.Status() < 400 && ww.panic == "" {
+			return
+		}
+	} else if r.LogSampleRate > 0 && r.LogSampleRate < 1 && ww.panic == "" {
+		sample := rand.Float64
+		if r.logSample != nil {
+			sample = r.logSample
+		}
+		if sample() >= r.LogSampleRate {
+			return
+		}
+	}
+	if r.LogHandler != nil {
+		r.LogHandler(LogEntry{
+			Method:     req.Method,
+			Path:       req.URL.Path,
+			Status:     ww.Status(),
+			Bytes:      ww.Size(),
+			Duration:   ww.Duration(),
+			RequestID:  ww.RequestID(),
+			RemoteAddr: req.RemoteAddr,
+			Panic:      ww.panic,
+			Stack:      ww.stack,
+		})
+		return
+	}
+	r.Logger(ww, req)
 }
@@ -0,0 +1,345 @@
+package violetear
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogHandler(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.LogRequests = true
+	router.RequestID = "Request-ID"
+	router.Logger = func(w *ResponseWriter, r *http.Request) {
+		t.Error("Logger should not be called")
+	}
+	var entries []LogEntry
+	router.LogHandler = func(e LogEntry) {
+		entries = append(entries, e)
+	}
+	router.HandleFunc("/test", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	}, "POST")
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/test?foo=bar", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("Request-ID", "abc")
+	router.ServeHTTP(w, req)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/test", nil)
+	router.ServeHTTP(w, req)
+
+	expect(t, len(entries), 2)
+	e := entries[0]
+	expect(t, e.Method, "POST")
+	expect(t, e.Path, "/test")
+	expect(t, e.Status, 201)
+	expect(t, e.Bytes, 5)
+	expect(t, e.Duration >= 5*time.Millisecond, true)
+	expect(t, e.RequestID, "abc")
+	expect(t, e.RemoteAddr, "10.0.0.1:1234")
+	e = entries[1]
+	expect(t, e.Method, "GET")
+	expect(t, e.Status, 405)
+	expect(t, len(e.RequestID), 36)
+}
+
+func TestLogHandlerPanic(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.LogRequests = true
+	router.RequestID = "Request-ID"
+	var entries []LogEntry
+	router.LogHandler = func(e LogEntry) {
+		entries = append(entries, e)
+	}
+	router.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("si si si")
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/panic", nil)
+	req.Header.Set("Request-ID", "abc")
+	router.ServeHTTP(w, req)
+
+	expect(t, w.Code, 500)
+	expect(t, len(entries), 1)
+	e := entries[0]
+	expect(t, e.Path, "/panic")
+	expect(t, e.Status, 500)
+	expect(t, e.RequestID, "abc")
+	expect(t, e.Panic, "si si si")
+}
+
+func TestLoggerPanic(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.LogRequests = true
+	var status int
+	router.Logger = func(w *ResponseWriter, r *http.Request) {
+		status = w.Status()
+	}
+	router.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("ja ja ja")
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/panic", nil)
+	router.ServeHTTP(w, req)
+	expect(t, status, 500)
+}
+
+func TestLogHandlerPanicStatus(t *testing.T) {
+	tt := []struct {
+		name    string
+		handler http.HandlerFunc
+		panic   http.HandlerFunc
+		code    int
+		status  int
+		body    string
+	}{
+		{"default", func(w http.ResponseWriter, r *http.Request) {
+			panic("si si si")
+		}, nil, 500, 500, "Internal Server Error\n"},
+		{"PanicHandler", func(w http.ResponseWriter, r *http.Request) {
+			panic("si si si")
+		}, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}, 503, 503, ""},
+		{"headers written", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte("partial"))
+			panic("si si si")
+		}, nil, 202, 202, "partial"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			router := New()
+			router.Verbose = false
+			router.LogRequests = true
+			router.PanicHandler = tc.panic
+			var entries []LogEntry
+			router.LogHandler = func(e LogEntry) {
+				entries = append(entries, e)
+			}
+			router.HandleFunc("/panic", tc.handler)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/panic", nil)
+			router.ServeHTTP(w, req)
+
+			expect(t, w.Code, tc.code)
+			expect(t, w.Body.String(), tc.body)
+			expect(t, len(entries), 1)
+			expect(t, entries[0].Status, tc.status)
+			expect(t, entries[0].Bytes, len(tc.body))
+		})
+	}
+}
+
+func TestLogHandlerNoLogRequests(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.LogHandler = func(e LogEntry) {
+		t.Error("LogHandler should not be called")
+	}
+	router.HandleFunc("/test", func(w http.ResponseWriter, r *http.Request) {})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/test", nil)
+	router.ServeHTTP(w, req)
+	expect(t, w.Code, 200)
+}
+
+func TestCommonLogFormat(t *testing.T) {
+	start := time.Date(2000, time.October, 10, 13, 55, 36, 0, time.FixedZone("", -7*3600))
+	tt := []struct {
+		name     string
+		user     string
+		referer  string
+		agent    string
+		body     string
+		common   string
+		combined string
+	}{
+		{"anonymous", "", "", "", "hello",
+			`127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET /index.html?q=1 HTTP/1.1" 201 5`,
+			`127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET /index.html?q=1 HTTP/1.1" 201 5 "-" "-"`},
+		{"user", "frank", "http://example.com/", "Mozilla/4.08", "",
+			`127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /index.html?q=1 HTTP/1.1" 201 -`,
+			`127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /index.html?q=1 HTTP/1.1" 201 - "http://example.com/" "Mozilla/4.08"`},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/index.html?q=1", nil)
+			req.RemoteAddr = "127.0.0.1:1234"
+			if tc.user != "" {
+				req.SetBasicAuth(tc.user, "secret")
+			}
+			if tc.referer != "" {
+				req.Header.Set("Referer", tc.referer)
+			}
+			if tc.agent != "" {
+				req.Header.Set("User-Agent", tc.agent)
+			}
+			ww := NewResponseWriter(httptest.NewRecorder(), "")
+			ww.start = start
+			ww.WriteHeader(http.StatusCreated)
+			ww.Write([]byte(tc.body))
+
+			var buf bytes.Buffer
+			CommonLogFormat(&buf)(ww, req)
+			expect(t, buf.String(), tc.common+"\n")
+			buf.Reset()
+			CombinedLogFormat(&buf)(ww, req)
+			expect(t, buf.String(), tc.combined+"\n")
+		})
+	}
+}
+
+func TestCombinedLogFormatRouter(t *testing.T) {
+	var buf bytes.Buffer
+	router := New()
+	router.Verbose = false
+	router.LogRequests = true
+	router.Logger = CombinedLogFormat(&buf)
+	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("User-Agent", "test")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	match, _ := regexp.MatchString(`^192\.0\.2\.1 - - \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "GET / HTTP/1\.1" 200 5 "-" "test"\n$`, buf.String())
+	expect(t, match, true)
+}
+
+func TestJSONLogger(t *testing.T) {
+	var buf bytes.Buffer
+	router := New()
+	router.Verbose = false
+	router.LogRequests = true
+	router.RequestID = "Request-ID"
+	router.Logger = JSONLogger(&buf)
+	router.HandleFunc("*", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("hello"))
+	})
+
+	req := httptest.NewRequest("POST", `/say/"hi"%20<there>`, nil)
+	req.Header.Set("Request-ID", "abc")
+	req.Header.Set("User-Agent", `agent "quoted" \ <x>`)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	req = httptest.NewRequest("GET", "/", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	expect(t, len(lines), 2)
+
+	var entry map[string]interface{}
+	expect(t, json.Unmarshal([]byte(lines[0]), &entry), nil)
+	expect(t, entry["method"], "POST")
+	expect(t, entry["path"], `/say/"hi" <there>`)
+	expect(t, entry["status"], float64(202))
+	expect(t, entry["bytes"], float64(5))
+	expect(t, entry["request_id"], "abc")
+	expect(t, entry["remote_addr"], "192.0.2.1:1234")
+	expect(t, entry["user_agent"], `agent "quoted" \ <x>`)
+	_, ok := entry["duration_ms"].(float64)
+	expect(t, ok, true)
+
+	entry = nil
+	expect(t, json.Unmarshal([]byte(lines[1]), &entry), nil)
+	expect(t, entry["method"], "GET")
+	expect(t, len(entry["request_id"].(string)), 36)
+}
+
+func TestLogOnlyErrors(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.LogRequests = true
+	router.LogOnlyErrors = true
+	var entries []LogEntry
+	router.LogHandler = func(e LogEntry) {
+		entries = append(entries, e)
+	}
+	router.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	router.HandleFunc("/fail", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+		http.Error(w, "fail", http.StatusInternalServerError)
+	})
+	router.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		panic("si si si")
+	})
+
+	for _, path := range []string{"/ok", "/fail", "/none", "/panic"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(w, req)
+	}
+
+	expect(t, len(entries), 3)
+	expect(t, entries[0].Path, "/fail")
+	expect(t, entries[0].Status, 500)
+	expect(t, entries[0].Bytes, 5)
+	expect(t, entries[0].Duration >= 5*time.Millisecond, true)
+	expect(t, entries[1].Path, "/none")
+	expect(t, entries[1].Status, 404)
+	expect(t, entries[2].Path, "/panic")
+	expect(t, entries[2].Panic, "si si si")
+}
+
+func TestLogSampleRate(t *testing.T) {
+	tt := []struct {
+		name       string
+		rate       float64
+		onlyErrors bool
+		path       string
+		min, max   int
+	}{
+		{"all", 0, false, "/ok", 1000, 1000},
+		{"one", 1, false, "/ok", 1000, 1000},
+		{"quarter", 0.25, false, "/ok", 200, 300},
+		{"half", 0.5, false, "/fail", 450, 550},
+		{"errors", 0.1, true, "/fail", 1000, 1000},
+		{"only errors", 0.1, true, "/ok", 0, 0},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			router := New()
+			router.Verbose = false
+			router.LogRequests = true
+			router.LogSampleRate = tc.rate
+			router.LogOnlyErrors = tc.onlyErrors
+			router.logSample = rand.New(rand.NewSource(1)).Float64
+			logged := 0
+			router.LogHandler = func(e LogEntry) {
+				logged++
+			}
+			router.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {})
+			router.HandleFunc("/fail", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			})
+			for i := 0; i < 1000; i++ {
+				w := httptest.NewRecorder()
+				req, _ := http.NewRequest("GET", tc.path, nil)
+				router.ServeHTTP(w, req)
+			}
+			if logged < tc.min || logged > tc.max {
+				t.Errorf("logged %d requests, expected between %d and %d", logged, tc.min, tc.max)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package violetear
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+)
+
+// maxBodyReader flags when the body is bigger than the limit
+type maxBodyReader struct {
+	io.ReadCloser
+	limit, read int64
+	exceeded    bool
+}
+
+// Read reads from the http.MaxBytesReader
+func (b *maxBodyReader) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if err != nil && err != io.EOF && b.read >= b.limit {
+		b.exceeded = true
+	}
+	return n, err
+}
+
+// maxBodyWriter keeps track of the written header
+type maxBodyWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+// WriteHeader satisfies the http.ResponseWriter interface
+func (w *maxBodyWriter) WriteHeader(statusCode int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write satisfies the http.ResponseWriter interface
+func (w *maxBodyWriter) Write(data []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(data)
+}
+
+// Flush satisfies the http.Flusher interface if the underlying
+// http.ResponseWriter supports it
+func (w *maxBodyWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.wroteHeader = true
+		f.Flush()
+	}
+}
+
+// Hijack satisfies the http.Hijacker interface if the underlying
+// http.ResponseWriter supports it
+func (w *maxBodyWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		conn, rw, err := h.Hijack()
+		if err == nil {
+			w.wroteHeader = true
+		}
+		return conn, rw, err
+	}
+	return nil, nil, errors.New("http.Hijacker not supported")
+}
+
+// MaxBodyBytes returns a middleware limiting the request body to n bytes
+// using http.MaxBytesReader. Requests with a bigger Content-Length get 413
+// Request Entity Too Large without calling the handler, if the limit is
+// reached while reading the body and the handler didn't respond, 413 is
+// returned too.
+func MaxBodyBytes(n int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > n {
+				http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+				return
+			}
+			if r.Body == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			// the caller's request is not modified
+			body := &maxBodyReader{ReadCloser: http.MaxBytesReader(w, r.Body, n), limit: n}
+			r2 := new(http.Request)
+			*r2 = *r
+			r2.Body = body
+			mw := &maxBodyWriter{ResponseWriter: w}
+			next.ServeHTTP(mw, r2)
+			if body.exceeded && !mw.wroteHeader {
+				http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			}
+		})
+	}
+}
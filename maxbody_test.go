@@ -0,0 +1,77 @@
+package violetear
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMaxBodyBytes(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.Use(MaxBodyBytes(10))
+	router.HandleFunc("/upload", func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return
+		}
+		w.Write(body)
+	})
+	router.HandleFunc("/respond", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := ioutil.ReadAll(r.Body); err != nil {
+			http.Error(w, "custom", http.StatusBadRequest)
+		}
+	})
+
+	tt := []struct {
+		name          string
+		path          string
+		body          string
+		contentLength bool
+		code          int
+		expect        string
+	}{
+		{"under", "/upload", "hello", true, 200, "hello"},
+		{"limit", "/upload", "0123456789", true, 200, "0123456789"},
+		{"over", "/upload", "0123456789a", true, 413, "Request Entity Too Large\n"},
+		{"over chunked", "/upload", "0123456789a", false, 413, "Request Entity Too Large\n"},
+		{"over handler responds", "/respond", "0123456789a", false, 400, "custom\n"},
+		{"empty", "/upload", "", true, 200, ""},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", tc.path, ioutil.NopCloser(strings.NewReader(tc.body)))
+			if tc.contentLength {
+				req.ContentLength = int64(len(tc.body))
+			}
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, tc.code)
+			expect(t, w.Body.String(), tc.expect)
+		})
+	}
+}
+
+func TestMaxBodyBytesWriter(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.Use(MaxBodyBytes(10))
+	router.HandleFunc("/stream", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("chunk"))
+		w.(http.Flusher).Flush()
+		_, _, err := w.(http.Hijacker).Hijack()
+		expect(t, err != nil, true)
+	})
+
+	w := httptest.NewRecorder()
+	body := ioutil.NopCloser(strings.NewReader("hello"))
+	req, _ := http.NewRequest("POST", "/stream", body)
+	router.ServeHTTP(w, req)
+	expect(t, w.Code, 200)
+	expect(t, w.Flushed, true)
+	expect(t, w.Body.String(), "chunk")
+	// the caller's request keeps its body
+	expect(t, req.Body, body)
+}
@@ -0,0 +1,39 @@
+package violetear
+
+import "net/http"
+
+// GET registers the handler for GET requests to path, same as
+// HandleFunc(path, handler, "GET")
+func (r *Router) GET(path string, handler http.HandlerFunc) *RouteHandle {
+	return r.HandleFunc(path, handler, http.MethodGet)
+}
+
+// POST registers the handler for POST requests to path
+func (r *Router) POST(path string, handler http.HandlerFunc) *RouteHandle {
+	return r.HandleFunc(path, handler, http.MethodPost)
+}
+
+// PUT registers the handler for PUT requests to path
+func (r *Router) PUT(path string, handler http.HandlerFunc) *RouteHandle {
+	return r.HandleFunc(path, handler, http.MethodPut)
+}
+
+// PATCH registers the handler for PATCH requests to path
+func (r *Router) PATCH(path string, handler http.HandlerFunc) *RouteHandle {
+	return r.HandleFunc(path, handler, http.MethodPatch)
+}
+
+// DELETE registers the handler for DELETE requests to path
+func (r *Router) DELETE(path string, handler http.HandlerFunc) *RouteHandle {
+	return r.HandleFunc(path, handler, http.MethodDelete)
+}
+
+// HEAD registers the handler for HEAD requests to path
+func (r *Router) HEAD(path string, handler http.HandlerFunc) *RouteHandle {
+	return r.HandleFunc(path, handler, http.MethodHead)
+}
+
+// OPTIONS registers the handler for OPTIONS requests to path
+func (r *Router) OPTIONS(path string, handler http.HandlerFunc) *RouteHandle {
+	return r.HandleFunc(path, handler, http.MethodOptions)
+}
@@ -0,0 +1,55 @@
+package violetear
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMethods(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Method))
+	}
+	tt := []struct {
+		method   string
+		register func(string, http.HandlerFunc) *RouteHandle
+		wrong    string
+	}{
+		{http.MethodGet, router.GET, http.MethodPost},
+		{http.MethodPost, router.POST, http.MethodGet},
+		{http.MethodPut, router.PUT, http.MethodPost},
+		{http.MethodPatch, router.PATCH, http.MethodPut},
+		{http.MethodDelete, router.DELETE, http.MethodGet},
+		{http.MethodHead, router.HEAD, http.MethodGet},
+		{http.MethodOptions, router.OPTIONS, http.MethodGet},
+	}
+	for _, tc := range tt {
+		t.Run(tc.method, func(t *testing.T) {
+			path := "/" + tc.method
+			expect(t, tc.register(path, handler) != nil, true)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tc.method, path, nil)
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, 200)
+			if tc.method != http.MethodHead {
+				expect(t, w.Body.String(), tc.method)
+			}
+
+			w = httptest.NewRecorder()
+			req, _ = http.NewRequest(tc.wrong, path, nil)
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, 405)
+			expect(t, w.Header().Get("Allow"), tc.method)
+		})
+	}
+}
+
+func TestMethodsError(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	expect(t, router.GET("/:missing", func(w http.ResponseWriter, r *http.Request) {}) == nil, true)
+	expect(t, router.GetError() != nil, true)
+}
@@ -0,0 +1,49 @@
+package violetear
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Mount delegates the requests under prefix to handler, example:
+//
+//	router.Mount("/debug/", http.DefaultServeMux)
+//
+// The prefix is removed from the path before calling the handler, a request to
+// /debug/vars is received as /vars and /debug as /.
+func (r *Router) Mount(prefix string, handler http.Handler) *RouteHandle {
+	prefix = strings.TrimSuffix(joinPath("/", prefix), "/")
+	h := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r2 := new(http.Request)
+		*r2 = *req
+		r2.URL = new(url.URL)
+		*r2.URL = *req.URL
+		r2.URL.Path = stripPrefix(req.URL.Path, prefix)
+		if req.URL.RawPath != "" {
+			r2.URL.RawPath = stripPrefix(req.URL.RawPath, prefix)
+		}
+		handler.ServeHTTP(w, r2)
+	})
+	base := prefix
+	if base == "" {
+		base = "/"
+	}
+	route := r.Handle(base, h)
+	if route == nil {
+		return nil
+	}
+	// the route returned configures both, Use applies to the prefix too
+	return r.Handle(prefix+"/*", h).join(route)
+}
+
+// stripPrefix removes prefix once from path keeping the leading slash
+func stripPrefix(path, prefix string) string {
+	if len(path) >= len(prefix) && strings.EqualFold(path[:len(prefix)], prefix) {
+		path = path[len(prefix):]
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return path
+}
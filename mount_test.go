@@ -0,0 +1,145 @@
+package violetear
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMount(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("index " + r.URL.Path))
+	})
+	mux.HandleFunc("/vars", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("vars"))
+	})
+	mux.HandleFunc("/a/b/c", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("nested " + r.URL.Path))
+	})
+
+	router := New()
+	router.Verbose = false
+	router.HandleFunc("/debugger", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("debugger"))
+	})
+	router.Mount("/debug/", mux)
+	expect(t, router.GetError(), nil)
+
+	tt := []struct {
+		path string
+		body string
+	}{
+		{"/debug", "index /"},
+		{"/debug/", "index /"},
+		{"/debug/vars", "vars"},
+		{"/debug/a/b/c", "nested /a/b/c"},
+		{"/debug/debug/x", "index /debug/x"},
+		{"/debugger", "debugger"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tc.path, nil)
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, 200)
+			expect(t, w.Body.String(), tc.body)
+			// the original request is not modified
+			expect(t, req.URL.Path, tc.path)
+		})
+	}
+}
+
+func TestMountUse(t *testing.T) {
+	auth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "secret" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	router := New()
+	router.Verbose = false
+	router.Mount("/admin", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	})).Use(auth)
+	expect(t, router.GetError(), nil)
+
+	for _, path := range []string{"/admin", "/admin/", "/admin/x"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(w, req)
+		expect(t, w.Code, http.StatusUnauthorized)
+
+		w = httptest.NewRecorder()
+		req.Header.Set("Authorization", "secret")
+		router.ServeHTTP(w, req)
+		expect(t, w.Code, http.StatusOK)
+	}
+}
+
+func TestMountRoot(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.Mount("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	for _, path := range []string{"/", "/x", "/x/y"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(w, req)
+		expect(t, w.Body.String(), path)
+	}
+}
+
+func TestStripPrefix(t *testing.T) {
+	expect(t, stripPrefix("/debug/vars", "/debug"), "/vars")
+	expect(t, stripPrefix("/debug", "/debug"), "/")
+	expect(t, stripPrefix("/debug/debug", "/debug"), "/debug")
+	expect(t, stripPrefix("/other", "/debug"), "/other")
+}
+
+func TestHandlerCompose(t *testing.T) {
+	header := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("X-Chain", name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	api := New()
+	api.Verbose = false
+	api.Use(header("api"))
+	api.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello " + r.URL.Path))
+	})
+
+	router := New()
+	router.Verbose = false
+	router.Use(header("router"))
+	router.Mount("/api", api.Handler())
+	expect(t, router.GetError(), nil)
+
+	// outer middleware wrapping the whole router
+	h := header("outer")(router.Handler())
+
+	// added after calling Handler
+	api.Use(header("late"))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/hello", nil)
+	h.ServeHTTP(w, req)
+	expect(t, w.Code, 200)
+	expect(t, w.Body.String(), "hello /hello")
+	expectDeepEqual(t, w.Header()["X-Chain"], []string{"outer", "router", "api", "late"})
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/none", nil)
+	h.ServeHTTP(w, req)
+	expect(t, w.Code, 404)
+	expectDeepEqual(t, w.Header()["X-Chain"], []string{"outer", "router", "api", "late"})
+}
@@ -1,7 +1,11 @@
 package violetear
 
 import (
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 )
 
 // Params string/interface map used with context
@@ -22,6 +26,90 @@ func (p Params) Add(k, v string) {
 	}
 }
 
+// clone returns a copy of the params
+func (p Params) clone() Params {
+	c := make(Params, len(p))
+	for k, v := range p {
+		if v, ok := v.([]string); ok {
+			c[k] = append([]string(nil), v...)
+			continue
+		}
+		c[k] = v
+	}
+	return c
+}
+
+// Get returns the value for key, the key can be used with or without the ":"
+// prefix, when having duplicate params the first value is returned.
+func (p Params) Get(key string) string {
+	var param interface{}
+	if !strings.HasPrefix(key, ":") && key != "*" {
+		param = p[":"+key]
+	}
+	if param == nil {
+		param = p[key]
+	}
+	switch param := param.(type) {
+	case string:
+		return param
+	case []string:
+		if len(param) > 0 {
+			return param[0]
+		}
+	}
+	return ""
+}
+
+// Int returns the value for key converted to int
+func (p Params) Int(key string) (int, error) {
+	v := p.Get(key)
+	if v == "" {
+		return 0, fmt.Errorf("param %q not found", key)
+	}
+	return strconv.Atoi(v)
+}
+
+// Int64 returns the value for key converted to int64
+func (p Params) Int64(key string) (int64, error) {
+	v := p.Get(key)
+	if v == "" {
+		return 0, fmt.Errorf("param %q not found", key)
+	}
+	return strconv.ParseInt(v, 10, 64)
+}
+
+// UUID returns the value of the ":uuid" param and true if it was set
+func (p Params) UUID() (string, bool) {
+	v := p.Get(":uuid")
+	return v, v != ""
+}
+
+// addQueryParams adds the first value of each query key to params, keys
+// already set or reserved ("rname", "*", ":named") are skipped
+func addQueryParams(params Params, query url.Values) Params {
+	for k, v := range query {
+		if len(v) == 0 || k == "rname" || k == "*" || strings.HasPrefix(k, ":") {
+			continue
+		}
+		if params == nil {
+			params = Params{}
+		}
+		if _, ok := params[k]; !ok {
+			params[k] = v[0]
+		}
+	}
+	return params
+}
+
+// GetAllParams returns the Params stored in the request context, an empty
+// Params is returned if the request has none.
+func GetAllParams(r *http.Request) Params {
+	if params, ok := r.Context().Value(ParamsKey).(Params); ok {
+		return params
+	}
+	return Params{}
+}
+
 // GetParam returns a value for the parameter set in path
 // When having duplicate params pass the index as the last argument to
 // retrieve the desired value.
@@ -78,3 +166,12 @@ func GetRouteName(r *http.Request) string {
 	}
 	return ""
 }
+
+// GetPattern returns the registered pattern of the matched route, example:
+// "/root/:uuid/item"
+func GetPattern(r *http.Request) string {
+	if pattern, ok := r.Context().Value(PatternKey).(string); ok {
+		return pattern
+	}
+	return ""
+}
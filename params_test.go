@@ -396,3 +396,193 @@ func TestGetParamsWildcard(t *testing.T) {
 	req, _ := http.NewRequest("GET", "/test/foo/bar/xxxx", nil)
 	router.ServeHTTP(w, req)
 }
+
+func TestParamsGet(t *testing.T) {
+	p := Params{}
+	p.Add(":id", "1")
+	p.Add(":id", "2")
+	p.Add(":uuid", "78F204D2-26D9-409F-BE81-2E5D061E1FA1")
+	p.Add("*", "catch")
+	p.Add("rname", "name")
+	p.Add(":big", "9223372036854775807")
+	p.Add(":bad", "12abc")
+	expect(t, p.Get("id"), "1")
+	expect(t, p.Get(":id"), "1")
+	expect(t, p.Get("*"), "catch")
+	expect(t, p.Get("rname"), "name")
+	expect(t, p.Get("none"), "")
+
+	i, err := p.Int("id")
+	expect(t, err, nil)
+	expect(t, i, 1)
+	_, err = p.Int("none")
+	expect(t, err != nil, true)
+	_, err = p.Int("bad")
+	expect(t, err != nil, true)
+
+	i64, err := p.Int64("big")
+	expect(t, err, nil)
+	expect(t, i64, int64(9223372036854775807))
+	_, err = p.Int64("none")
+	expect(t, err != nil, true)
+	_, err = p.Int64("bad")
+	expect(t, err != nil, true)
+
+	uuid, ok := p.UUID()
+	expect(t, ok, true)
+	expect(t, uuid, "78F204D2-26D9-409F-BE81-2E5D061E1FA1")
+	_, ok = Params{}.UUID()
+	expect(t, ok, false)
+}
+
+func TestGetAllParams(t *testing.T) {
+	router := New()
+	router.AddRegex(":id", `\d+`)
+	router.HandleFunc("/test/:id", func(w http.ResponseWriter, r *http.Request) {
+		id, err := GetAllParams(r).Int("id")
+		expect(t, err, nil)
+		expect(t, id, 42)
+	})
+	router.HandleFunc("/static", func(w http.ResponseWriter, r *http.Request) {
+		p := GetAllParams(r)
+		expect(t, len(p), 0)
+		expect(t, p.Get("id"), "")
+	})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/test/42", nil)
+	router.ServeHTTP(w, req)
+	expect(t, w.Code, 200)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/static", nil)
+	router.ServeHTTP(w, req)
+	expect(t, w.Code, 200)
+}
+
+func TestQueryParams(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.AddRegex(":page", `\d+`)
+	router.HandleFunc("/list/:page", func(w http.ResponseWriter, r *http.Request) {
+		p := GetAllParams(r)
+		fmt.Fprintf(w, "%s %s %s %s", p.Get("page"), p.Get("sort"), p.Get("q"), GetRouteName(r))
+	}).Name("list")
+	router.HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) {
+		p := GetAllParams(r)
+		fmt.Fprintf(w, "%s %s %d", p.Get("q"), p.Get("id"), len(p))
+	})
+
+	tt := []struct {
+		name        string
+		queryParams bool
+		path        string
+		body        string
+	}{
+		{"disabled", false, "/list/1?sort=asc", "1   list"},
+		{"path and query", true, "/list/1?sort=asc&q=go", "1 asc go list"},
+		{"first value", true, "/list/1?sort=asc&sort=desc", "1 asc  list"},
+		{"collision", true, "/list/1?page=2&sort=asc", "1 asc  list"},
+		{"reserved", true, "/list/1?rname=foo&:page=3", "1   list"},
+		{"no params", true, "/search", "  0"},
+		{"only query", true, "/search?q=go&id=3", "go 3 2"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			router.QueryParams = tc.queryParams
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tc.path, nil)
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, 200)
+			expect(t, w.Body.String(), tc.body)
+		})
+	}
+}
+
+func TestGetAllParamsNoContext(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/", nil)
+	p := GetAllParams(req)
+	expect(t, p != nil, true)
+	expect(t, len(p), 0)
+	expect(t, p.Get("*"), "")
+
+	// catch-all always has params
+	router := New()
+	router.Verbose = false
+	router.HandleFunc("*", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(GetAllParams(r).Get("*")))
+	})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	expect(t, w.Code, 200)
+	expect(t, w.Body.String(), "/")
+}
+
+func TestGetPattern(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.AddRegex(":uuid", `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+	var pattern string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		pattern = GetPattern(r)
+	}
+	router.HandleFunc("/root/:uuid/item", handler)
+	router.HandleFunc("/static/*", handler)
+	router.HandleFunc("/hello", handler)
+	router.HandleFunc("/hello#v2", handler)
+
+	tt := []struct {
+		path    string
+		pattern string
+	}{
+		{"/root/" + genUUID() + "/item", "/root/:uuid/item"},
+		{"/static/app.css", "/static/*"},
+		{"/hello", "/hello"},
+		{"/missing", ""},
+	}
+	for _, tc := range tt {
+		pattern = "none"
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		router.ServeHTTP(w, req)
+		if tc.pattern == "" {
+			expect(t, w.Code, 404)
+			continue
+		}
+		expect(t, pattern, tc.pattern)
+	}
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	expect(t, GetPattern(req), "")
+}
+
+func TestParamsAlwaysInContext(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		params, ok := r.Context().Value(ParamsKey).(Params)
+		expect(t, ok, true)
+		w.Write([]byte(params.Get("*")))
+	}
+	router.HandleFunc("*", handler)
+	router.HandleFunc("/hello", handler)
+	router.HandleFunc("/static/*", handler)
+	router.HandleFunc("/files/*filepath", handler)
+
+	tt := []struct {
+		path   string
+		expect string
+	}{
+		{"/", "/"},
+		{"/hello", ""},
+		{"/world", "world"},
+		{"/static/app.css", "app.css"},
+		{"/files/css/app.css", "css/app.css"},
+	}
+	for _, tc := range tt {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		router.ServeHTTP(w, req)
+		expect(t, w.Code, 200)
+		expect(t, w.Body.String(), tc.expect)
+	}
+}
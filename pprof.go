@@ -0,0 +1,37 @@
+package violetear
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"strings"
+)
+
+// RegisterPprof serves the net/http/pprof handlers under prefix wrapped with
+// the optional middleware, example:
+//
+//	router.RegisterPprof("/debug/pprof", auth)
+//
+// The index is available at /debug/pprof/ and the profiles at
+// /debug/pprof/heap, /debug/pprof/goroutine, etc.
+func (r *Router) RegisterPprof(prefix string, mw ...func(http.Handler) http.Handler) *RouteHandle {
+	var h http.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch name := strings.Trim(req.URL.Path, "/"); name {
+		case "":
+			pprof.Index(w, req)
+		case "cmdline":
+			pprof.Cmdline(w, req)
+		case "profile":
+			pprof.Profile(w, req)
+		case "symbol":
+			pprof.Symbol(w, req)
+		case "trace":
+			pprof.Trace(w, req)
+		default:
+			pprof.Handler(name).ServeHTTP(w, req)
+		}
+	})
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return r.Mount(prefix, h)
+}
@@ -0,0 +1,72 @@
+package violetear
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegisterPprof(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.RegisterPprof("/debug/pprof")
+	expect(t, router.GetError(), nil)
+
+	tt := []struct {
+		path     string
+		code     int
+		contains string
+	}{
+		{"/debug/pprof/", 200, "goroutine"},
+		{"/debug/pprof/goroutine?debug=1", 200, "goroutine profile"},
+		{"/debug/pprof/cmdline", 200, ""},
+		{"/debug/pprof/symbol", 200, "num_symbols"},
+		{"/debug/pprof/unknown", 404, "Unknown profile"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tc.path, nil)
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, tc.code)
+			expect(t, strings.Contains(w.Body.String(), tc.contains), true)
+		})
+	}
+}
+
+func TestRegisterPprofMiddleware(t *testing.T) {
+	auth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "secret" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	router := New()
+	router.Verbose = false
+	router.RegisterPprof("/debug/pprof", auth)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/debug/pprof/", nil)
+	router.ServeHTTP(w, req)
+	expect(t, w.Code, http.StatusUnauthorized)
+
+	w = httptest.NewRecorder()
+	req.Header.Set("Authorization", "secret")
+	router.ServeHTTP(w, req)
+	expect(t, w.Code, http.StatusOK)
+
+	// the index is wrapped by Use too
+	router = New()
+	router.Verbose = false
+	router.RegisterPprof("/debug/pprof").Use(auth)
+	for _, path := range []string{"/debug/pprof", "/debug/pprof/", "/debug/pprof/cmdline"} {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", path, nil)
+		router.ServeHTTP(w, req)
+		expect(t, w.Code, http.StatusUnauthorized)
+	}
+}
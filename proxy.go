@@ -0,0 +1,55 @@
+package violetear
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// inNets returns true if ip belongs to any of nets
+func inNets(ip net.IP, nets []net.IPNet) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseIP returns the IP of addr removing the port if any
+func parseIP(addr string) net.IP {
+	return net.ParseIP(stripPort(strings.TrimSpace(addr)))
+}
+
+// clientIP returns the address of the client when the peer is a trusted
+// proxy, the rightmost address of X-Forwarded-For that is not in the
+// TrustedProxies or X-Real-IP if there is no X-Forwarded-For header, false if
+// the peer is not trusted or the headers have no valid address.
+func (r *Router) clientIP(req *http.Request) (string, bool) {
+	peer := parseIP(req.RemoteAddr)
+	if peer == nil || !(r.TrustProxy || inNets(peer, r.TrustedProxies)) {
+		return "", false
+	}
+	if xff := req.Header["X-Forwarded-For"]; len(xff) > 0 {
+		hops := strings.Split(strings.Join(xff, ","), ",")
+		var client net.IP
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := parseIP(hops[i])
+			if ip == nil {
+				break
+			}
+			client = ip
+			if !inNets(ip, r.TrustedProxies) {
+				break
+			}
+		}
+		if client == nil {
+			return "", false
+		}
+		return client.String(), true
+	}
+	if ip := parseIP(req.Header.Get("X-Real-IP")); ip != nil {
+		return ip.String(), true
+	}
+	return "", false
+}
@@ -0,0 +1,88 @@
+package violetear
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrustedProxies(t *testing.T) {
+	_, lb, _ := net.ParseCIDR("10.0.0.0/8")
+	tt := []struct {
+		name       string
+		trustAll   bool
+		remoteAddr string
+		xff        []string
+		realIP     string
+		expect     string
+	}{
+		{"no headers", false, "10.0.0.1:1234", nil, "", "10.0.0.1:1234"},
+		{"trusted", false, "10.0.0.1:1234", []string{"203.0.113.7"}, "", "203.0.113.7"},
+		{"untrusted", false, "192.0.2.1:1234", []string{"203.0.113.7"}, "", "192.0.2.1:1234"},
+		{"spoofed", false, "10.0.0.1:1234", []string{"1.1.1.1, 203.0.113.7, 10.0.0.2"}, "", "203.0.113.7"},
+		{"multiple headers", false, "10.0.0.1:1234", []string{"1.1.1.1", "203.0.113.7"}, "", "203.0.113.7"},
+		{"all trusted", false, "10.0.0.1:1234", []string{"10.0.0.3, 10.0.0.2"}, "", "10.0.0.3"},
+		{"port", false, "10.0.0.1:1234", []string{"[2001:db8::1]:443"}, "", "2001:db8::1"},
+		{"invalid", false, "10.0.0.1:1234", []string{"unknown"}, "", "10.0.0.1:1234"},
+		{"real ip", false, "10.0.0.1:1234", nil, "203.0.113.7", "203.0.113.7"},
+		{"real ip untrusted", false, "192.0.2.1:1234", nil, "203.0.113.7", "192.0.2.1:1234"},
+		{"trust any peer", true, "192.0.2.1:1234", []string{"1.1.1.1, 203.0.113.7"}, "", "203.0.113.7"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			router := New()
+			router.Verbose = false
+			router.TrustProxy = tc.trustAll
+			if !tc.trustAll {
+				router.TrustedProxies = []net.IPNet{*lb}
+			}
+			router.LogRequests = true
+			var entry LogEntry
+			router.LogHandler = func(e LogEntry) {
+				entry = e
+			}
+			router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(r.RemoteAddr))
+			})
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tc.remoteAddr
+			for _, v := range tc.xff {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			if tc.realIP != "" {
+				req.Header.Set("X-Real-IP", tc.realIP)
+			}
+			router.ServeHTTP(w, req)
+			expect(t, w.Body.String(), tc.expect)
+			expect(t, entry.RemoteAddr, tc.expect)
+		})
+	}
+}
+
+func TestTrustedProxiesRateLimit(t *testing.T) {
+	_, lb, _ := net.ParseCIDR("10.0.0.0/8")
+	router := New()
+	router.Verbose = false
+	router.TrustedProxies = []net.IPNet{*lb}
+	router.Use(RateLimit(1, 1, nil))
+	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	tt := []struct {
+		client string
+		code   int
+	}{
+		{"203.0.113.7", 200},
+		{"203.0.113.7", 429},
+		{"203.0.113.8", 200},
+	}
+	for _, tc := range tt {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("X-Forwarded-For", tc.client)
+		router.ServeHTTP(w, req)
+		expect(t, w.Code, tc.code)
+	}
+}
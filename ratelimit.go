@@ -0,0 +1,94 @@
+package violetear
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// rateLimiter keeps a token bucket per key
+type rateLimiter struct {
+	sync.Mutex
+	rate      float64
+	burst     float64
+	buckets   map[string]*bucket
+	lastPrune time.Time
+}
+
+// bucket tokens available and the time they were last updated
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// allow takes a token from the key bucket, if there are no tokens left
+// returns false and the time to wait for the next one
+func (l *rateLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	l.Lock()
+	defer l.Unlock()
+
+	l.prune(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// prune removes the buckets that are full again, runs at most once per the
+// time needed to refill a bucket
+func (l *rateLimiter) prune(now time.Time) {
+	idle := time.Duration(l.burst / l.rate * float64(time.Second))
+	if now.Sub(l.lastPrune) < idle {
+		return
+	}
+	for k, b := range l.buckets {
+		if now.Sub(b.last) >= idle {
+			delete(l.buckets, k)
+		}
+	}
+	l.lastPrune = now
+}
+
+// RateLimit returns a middleware limiting the requests per second for each
+// key using a token bucket of size burst, keyFunc defaults to the client IP.
+// When the limit is exceeded it responds with 429 and the Retry-After header.
+// Idle buckets are pruned while handling requests.
+func RateLimit(rps int, burst int, keyFunc func(*http.Request) string) func(http.Handler) http.Handler {
+	if burst < 1 {
+		burst = 1
+	}
+	if keyFunc == nil {
+		keyFunc = func(r *http.Request) string {
+			return stripPort(r.RemoteAddr)
+		}
+	}
+	l := &rateLimiter{
+		rate:    float64(rps),
+		burst:   float64(burst),
+		buckets: map[string]*bucket{},
+	}
+	return func(next http.Handler) http.Handler {
+		if rps <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ok, wait := l.allow(keyFunc(r), time.Now()); !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
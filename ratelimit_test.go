@@ -0,0 +1,81 @@
+package violetear
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.Use(RateLimit(10, 2, nil))
+	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	request := func(addr string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.RemoteAddr = addr
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	expect(t, request("10.0.0.1:1234").Code, 200)
+	expect(t, request("10.0.0.1:1235").Code, 200)
+	w := request("10.0.0.1:1236")
+	expect(t, w.Code, 429)
+	expect(t, w.Header().Get("Retry-After"), "1")
+
+	// other client
+	expect(t, request("10.0.0.2:1234").Code, 200)
+
+	// recovery
+	time.Sleep(150 * time.Millisecond)
+	expect(t, request("10.0.0.1:1234").Code, 200)
+}
+
+func TestRateLimitKeyFunc(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.Use(RateLimit(1, 5, func(r *http.Request) string {
+		return r.Header.Get("X-API-Key")
+	}))
+	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	codes := map[int]int{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/", nil)
+			req.Header.Set("X-API-Key", "key")
+			router.ServeHTTP(w, req)
+			mu.Lock()
+			codes[w.Code]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	expect(t, codes[200], 5)
+	expect(t, codes[429], 15)
+}
+
+func TestRateLimitPrune(t *testing.T) {
+	l := &rateLimiter{rate: 1, burst: 2, buckets: map[string]*bucket{}}
+	now := time.Now()
+	l.allow("a", now)
+	l.allow("b", now)
+	expect(t, len(l.buckets), 2)
+	ok, wait := l.allow("a", now.Add(time.Second))
+	expect(t, ok, true)
+	expect(t, wait, time.Duration(0))
+	l.allow("c", now.Add(3*time.Second))
+	expect(t, len(l.buckets), 1)
+	_, ok = l.buckets["c"]
+	expect(t, ok, true)
+}
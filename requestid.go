@@ -0,0 +1,33 @@
+package violetear
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/http"
+)
+
+// newRequestID returns a random (version 4) UUID
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// RequestID returns the request ID, the value of the RequestID header or the
+// generated one, empty if the Router RequestID is not set. It is available to
+// the global and route middleware and to the handlers.
+func RequestID(r *http.Request) string {
+	if rid, ok := r.Context().Value(requestIDKey).(string); ok {
+		return rid
+	}
+	return ""
+}
+
+// RequestIDFromContext returns the request ID, same as RequestID
+func RequestIDFromContext(r *http.Request) string {
+	return RequestID(r)
+}
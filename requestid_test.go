@@ -0,0 +1,86 @@
+package violetear
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func TestNewRequestID(t *testing.T) {
+	rx := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		id := newRequestID()
+		expect(t, rx.MatchString(id), true)
+		expect(t, seen[id], false)
+		seen[id] = true
+	}
+}
+
+func TestRequestIDFromContext(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.RequestID = "X-Request-ID"
+	var rid string
+	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		rid = RequestIDFromContext(r)
+	})
+
+	// echo
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-ID", "abc")
+	router.ServeHTTP(w, req)
+	expect(t, rid, "abc")
+	expect(t, w.Header().Get("X-Request-ID"), "abc")
+
+	// generate
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/", nil)
+	router.ServeHTTP(w, req)
+	expect(t, len(rid), 36)
+	expect(t, w.Header().Get("X-Request-ID"), rid)
+
+	// disabled
+	router.RequestID = ""
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	expect(t, rid, "")
+	expect(t, w.Header().Get("X-Request-ID"), "")
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.LogRequests = true
+	router.RequestID = "X-Request-ID"
+	var logged, global, route, handler string
+	router.Logger = func(w *ResponseWriter, r *http.Request) {
+		logged = w.RequestID()
+	}
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			global = RequestID(r)
+			next.ServeHTTP(w, r)
+		})
+	})
+	router.HandleWithMiddleware("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler = RequestID(r)
+	}), []func(http.Handler) http.Handler{func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route = RequestID(r)
+			next.ServeHTTP(w, r)
+		})
+	}})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	router.ServeHTTP(w, req)
+	rid := w.Header().Get("X-Request-ID")
+	expect(t, len(rid), 36)
+	expect(t, handler, rid)
+	expect(t, route, rid)
+	expect(t, global, rid)
+	expect(t, logged, rid)
+}
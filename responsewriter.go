@@ -1,7 +1,11 @@
 package violetear
 
 import (
+	"bufio"
+	"errors"
+	"net"
 	"net/http"
+	"strconv"
 	"time"
 )
 
@@ -11,6 +15,12 @@ type ResponseWriter struct {
 	requestID    string
 	size, status int
 	start        time.Time
+	duration     time.Duration
+	wroteHeader  bool
+	// panic message of the recovered handler, empty if it didn't panic
+	panic string
+	// stack trace of the panic if the Router LogPanicStack is true
+	stack string
 }
 
 // NewResponseWriter returns ResponseWriter
@@ -33,27 +43,134 @@ func (w *ResponseWriter) Size() int {
 	return w.size
 }
 
+// BytesWritten returns the response size in bytes, same as Size
+func (w *ResponseWriter) BytesWritten() int {
+	return w.size
+}
+
 // RequestTime return the request time
 func (w *ResponseWriter) RequestTime() string {
 	return time.Since(w.start).String()
 }
 
+// Duration returns the time it took to handle the request, it is set by the
+// Router right before calling the Logger
+func (w *ResponseWriter) Duration() time.Duration {
+	return w.duration
+}
+
 // RequestID retrieve the Request ID
 func (w *ResponseWriter) RequestID() string {
 	return w.requestID
 }
 
+// Stack returns the stack trace of the recovered panic when the Router
+// LogPanicStack is true, empty otherwise
+func (w *ResponseWriter) Stack() string {
+	return w.stack
+}
+
 // Write satisfies the http.ResponseWriter interface and
 // captures data written, in bytes
 func (w *ResponseWriter) Write(data []byte) (int, error) {
+	w.wroteHeader = true
 	size, err := w.ResponseWriter.Write(data)
 	w.size += size
 	return size, err
 }
 
 // WriteHeader satisfies the http.ResponseWriter interface and
-// allows us to catch the status code
+// allows us to catch the status code, only the first call is forwarded
 func (w *ResponseWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
 	w.status = statusCode
+	w.wroteHeader = true
 	w.ResponseWriter.WriteHeader(statusCode)
 }
+
+// Flush satisfies the http.Flusher interface if the underlying
+// http.ResponseWriter supports it, flushing before WriteHeader sends an
+// implicit 200
+func (w *ResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.wroteHeader = true
+		f.Flush()
+	}
+}
+
+// Hijack satisfies the http.Hijacker interface if the underlying
+// http.ResponseWriter supports it, required by WebSocket handlers
+func (w *ResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		conn, rw, err := h.Hijack()
+		if err == nil {
+			w.wroteHeader = true
+		}
+		return conn, rw, err
+	}
+	return nil, nil, errors.New("http.Hijacker not supported")
+}
+
+// headResponseWriter discards the body, used to answer HEAD requests with a
+// GET handler while preserving the headers and status code. The status code
+// is delayed until the handler returns so that the Content-Length of the
+// discarded body can be set like the GET response.
+type headResponseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+// WriteHeader keeps the status code until the handler returns, informational
+// status codes are sent right away
+func (w *headResponseWriter) WriteHeader(statusCode int) {
+	if statusCode >= 100 && statusCode < 200 {
+		w.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	if w.status == 0 {
+		w.status = statusCode
+	}
+}
+
+// Write discards the data counting its length
+func (w *headResponseWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.size += len(data)
+	return len(data), nil
+}
+
+// close sets the Content-Length if the handler didn't and writes the status
+// code
+func (w *headResponseWriter) close() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	h := w.Header()
+	if h.Get("Content-Length") == "" && h.Get("Transfer-Encoding") == "" &&
+		w.status != http.StatusNoContent && w.status != http.StatusNotModified {
+		h.Set("Content-Length", strconv.Itoa(w.size))
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+// autoHead answers a HEAD request with a GET handler discarding the body
+type autoHead struct {
+	http.Handler
+}
+
+// ServeHTTP calls the handler with a ResponseWriter that discards the body
+func (h autoHead) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	hw := &headResponseWriter{ResponseWriter: w}
+	h.Handler.ServeHTTP(hw, r)
+	hw.close()
+}
+
+// headHandler calls h with a ResponseWriter that discards the body
+func headHandler(h http.Handler) http.Handler {
+	return autoHead{h}
+}
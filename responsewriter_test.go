@@ -1,6 +1,9 @@
 package violetear
 
 import (
+	"bufio"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -144,3 +147,176 @@ func TestResponseWriterLoggerCloseConnection(t *testing.T) {
 	}
 	client.Get(ts.URL)
 }
+
+func TestResponseWriterDuration(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.LogRequests = true
+	var duration time.Duration
+	router.Logger = func(w *ResponseWriter, r *http.Request) {
+		duration = w.Duration()
+	}
+	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+	})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	router.ServeHTTP(w, req)
+	expect(t, duration >= 10*time.Millisecond, true)
+
+	rw := NewResponseWriter(httptest.NewRecorder(), "")
+	expect(t, rw.Duration(), time.Duration(0))
+}
+
+func TestResponseWriterBytesWritten(t *testing.T) {
+	tt := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"implicit 200", 0, "hello"},
+		{"404", 404, "not found"},
+		{"404 no body", 404, ""},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			rw := NewResponseWriter(rec, "")
+			if tc.status != 0 {
+				rw.WriteHeader(tc.status)
+			}
+			rw.Write([]byte(tc.body))
+			// superfluous WriteHeader is not recorded
+			rw.WriteHeader(http.StatusInternalServerError)
+			if tc.status == 0 {
+				tc.status = 200
+			}
+			expect(t, rw.Status(), tc.status)
+			expect(t, rec.Code, tc.status)
+			expect(t, rw.BytesWritten(), len(tc.body))
+			expect(t, rw.Size(), len(tc.body))
+		})
+	}
+}
+
+func TestResponseWriterFlush(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rw := NewResponseWriter(rec, "")
+	var _ http.Flusher = rw
+	rw.Write([]byte("data"))
+	rw.Flush()
+	expect(t, rec.Flushed, true)
+}
+
+func TestResponseWriterHijackNotSupported(t *testing.T) {
+	rw := NewResponseWriter(httptest.NewRecorder(), "")
+	var _ http.Hijacker = rw
+	_, _, err := rw.Hijack()
+	expect(t, err != nil, true)
+}
+
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (h *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h.hijacked = true
+	return nil, nil, nil
+}
+
+func TestResponseWriterHijack(t *testing.T) {
+	rec := &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+	rw := NewResponseWriter(rec, "")
+	_, _, err := rw.Hijack()
+	expect(t, err, nil)
+	expect(t, rec.hijacked, true)
+}
+
+func TestResponseWriterHijackLogRequests(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.LogRequests = true
+	router.Logger = func(w *ResponseWriter, r *http.Request) {}
+	router.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		hj, ok := w.(http.Hijacker)
+		if !ok {
+			http.Error(w, "no hijacker", http.StatusInternalServerError)
+			return
+		}
+		conn, buf, err := hj.Hijack()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\nhello")
+		buf.Flush()
+	})
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("GET /ws HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n"))
+	br := bufio.NewReader(conn)
+	res, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, res.StatusCode, http.StatusSwitchingProtocols)
+	body, _ := ioutil.ReadAll(br)
+	expect(t, string(body), "hello")
+}
+
+func TestResponseWriterFlushWriteHeader(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rw := NewResponseWriter(rec, "")
+	rw.Flush()
+	rw.WriteHeader(http.StatusNotFound)
+	expect(t, rec.Flushed, true)
+	expect(t, rec.Code, http.StatusOK)
+	expect(t, rw.Status(), http.StatusOK)
+}
+
+func TestResponseWriterSSE(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.LogRequests = true
+	router.Logger = func(w *ResponseWriter, r *http.Request) {}
+	next := make(chan struct{})
+	router.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		f, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "no flusher", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; i < 3; i++ {
+			w.Write([]byte("data: ping\n\n"))
+			f.Flush()
+			<-next
+		}
+	})
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	expect(t, res.StatusCode, http.StatusOK)
+	br := bufio.NewReader(res.Body)
+	for i := 0; i < 3; i++ {
+		// each chunk arrives before the handler writes the next one
+		line, err := br.ReadString('\n')
+		expect(t, err, nil)
+		expect(t, line, "data: ping\n")
+		br.ReadString('\n')
+		next <- struct{}{}
+	}
+}
@@ -0,0 +1,295 @@
+package violetear
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// RouteInfo describes a registered route
+type RouteInfo struct {
+	Path    string
+	Methods []string
+	Version string
+	Name    string
+}
+
+// Route describes a route to register with HandleRoutes, the Middleware
+// wraps the Handler like HandleWithMiddleware and the Name is used by URL.
+type Route struct {
+	Path       string
+	Handler    http.Handler
+	Methods    []string
+	Version    string
+	Name       string
+	Middleware []func(http.Handler) http.Handler
+}
+
+// RouteHandle is returned by Handle to configure the registered route, it
+// embeds the node of the path. Use, Version and Priority apply only to the
+// handlers registered by the Handle call returning it, not to the other
+// methods of the path. Name, Use, Version and Priority can be chained, if the
+// route failed they do nothing and the error is available with GetError:
+//
+//	router.HandleFunc("/admin", admin, "GET").Use(auth).Name("admin")
+type RouteHandle struct {
+	*Trie
+	router *Router
+	// paths registered without the version, Mount registers the prefix and
+	// its catch-all
+	paths   []string
+	methods string
+	handler http.Handler
+	weight  int
+	// optional the last segment is optional, "/posts/:page?"
+	optional bool
+	// nodes holding the handlers, the path without the optional segment too
+	nodes []*Trie
+}
+
+// own marks the handlers of node added after the first n as registered by h
+func (h *RouteHandle) own(node *Trie, n int) {
+	for i := n; i < len(node.Handler); i++ {
+		node.Handler[i].route = h
+	}
+	h.nodes = append(h.nodes, node)
+}
+
+// join adds the handlers of other, registered with the same handler for
+// another path, to h so that the route is configured as one
+func (h *RouteHandle) join(other *RouteHandle) *RouteHandle {
+	if h == nil || other == nil {
+		return nil
+	}
+	h.router.mu.Lock()
+	defer h.router.mu.Unlock()
+	h.adopt(other)
+	return h
+}
+
+// adopt marks the handlers of other as registered by h
+func (h *RouteHandle) adopt(other *RouteHandle) {
+	for _, node := range other.nodes {
+		for i := range node.Handler {
+			if node.Handler[i].route == other {
+				node.Handler[i].route = h
+			}
+		}
+		h.nodes = append(h.nodes, node)
+	}
+	h.paths = append(h.paths, other.paths...)
+}
+
+// Name sets the name of the route to be used by URL like HandleNamed
+func (h *RouteHandle) Name(name string) *RouteHandle {
+	if h == nil {
+		return nil
+	}
+	h.router.mu.Lock()
+	defer h.router.mu.Unlock()
+	h.name = name
+	if h.optional {
+		h.router.names[name] = h.pattern + "?"
+	} else {
+		h.router.names[name] = h.pattern
+	}
+	return h
+}
+
+// Priority sets the priority of the route for its methods, by default a
+// static segment is matched before a ":named" one and the catch-all is used
+// last, a ":named" segment of a route with a higher priority is tried before
+// the static siblings and the ":named" ones with a lower priority, the
+// catch-all is always used last. The default priority is 0.
+func (h *RouteHandle) Priority(weight int) *RouteHandle {
+	if h == nil {
+		return nil
+	}
+	h.router.mu.Lock()
+	defer h.router.mu.Unlock()
+	h.weight = weight
+	h.updatePriority()
+	return h
+}
+
+// updatePriority updates the priority of the nodes of the route and their
+// parents
+func (h *RouteHandle) updatePriority() {
+	for _, node := range h.nodes {
+		for n := node; n != nil; n = n.parent {
+			n.updatePriority()
+		}
+	}
+}
+
+// Use wraps the handlers of the route with the middleware like
+// HandleWithMiddleware
+func (h *RouteHandle) Use(mw ...func(http.Handler) http.Handler) *RouteHandle {
+	if h == nil {
+		return nil
+	}
+	h.router.mu.Lock()
+	defer h.router.mu.Unlock()
+	for i := len(mw) - 1; i >= 0; i-- {
+		h.handler = mw[i](h.handler)
+	}
+	for _, node := range h.nodes {
+		for i := range node.Handler {
+			if node.Handler[i].route == h {
+				node.Handler[i].Handler = h.handler
+			}
+		}
+	}
+	return h
+}
+
+// Version moves the handlers of the route to the version like registering
+// the path with "#version", "/users#v2"
+func (h *RouteHandle) Version(version string) *RouteHandle {
+	if h == nil {
+		return nil
+	}
+	r := h.router
+	r.mu.RLock()
+	current, handler := h.version, h.handler
+	r.mu.RUnlock()
+	if version == current {
+		return h
+	}
+	var moved []*RouteHandle
+	for _, path := range h.paths {
+		if version != "" {
+			path += "#" + version
+		}
+		m := r.Handle(path, handler, h.methods)
+		if m == nil {
+			return nil
+		}
+		moved = append(moved, m)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if moved[0].name == "" {
+		moved[0].name = h.name
+	}
+	for _, node := range h.nodes {
+		r.removeHandlers(node.segments(), nil, node.version, h)
+	}
+	h.Trie, h.nodes, h.paths = moved[0].Trie, nil, nil
+	for _, m := range moved {
+		h.adopt(m)
+	}
+	if h.weight != 0 {
+		h.updatePriority()
+	}
+	return h
+}
+
+// HandleRoutes registers the routes in order, the routes that fail don't stop
+// the others and an error is returned for every one of them, nil if all the
+// routes were registered.
+func (r *Router) HandleRoutes(routes []Route) []error {
+	var errs []error
+	for _, rt := range routes {
+		if rt.Handler == nil {
+			errs = append(errs, fmt.Errorf("route %q: nil handler", rt.Path))
+			continue
+		}
+		handler := rt.Handler
+		for i := len(rt.Middleware) - 1; i >= 0; i-- {
+			handler = rt.Middleware[i](handler)
+		}
+		path := rt.Path
+		if rt.Version != "" {
+			path += "#" + rt.Version
+		}
+		var trie *RouteHandle
+		if rt.Name != "" {
+			trie = r.HandleNamed(rt.Name, path, handler, rt.Methods...)
+		} else {
+			trie = r.Handle(path, handler, rt.Methods...)
+		}
+		if trie == nil {
+			errs = append(errs, fmt.Errorf("route %q: %s", rt.Path, r.GetError()))
+		}
+	}
+	return errs
+}
+
+// Routes returns the registered routes sorted by path and version
+func (r *Router) Routes() []RouteInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var routes []RouteInfo
+	r.routes.walk("", func(path string, node *Trie) {
+		if node.trailingSlash {
+			path += "/"
+		}
+		routes = append(routes, RouteInfo{
+			Path:    path,
+			Methods: node.methods(),
+			Version: node.version,
+			Name:    node.name,
+		})
+	})
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path == routes[j].Path {
+			return routes[i].Version < routes[j].Version
+		}
+		return routes[i].Path < routes[j].Path
+	})
+	return routes
+}
+
+// AllowedMethods returns the methods registered for the path as it was
+// registered, "/users/:id" or "/users#v2" for a version, an error is
+// returned if the path has no handlers.
+func (r *Router) AllowedMethods(path string) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var version string
+	if i := strings.Index(path, "#"); i != -1 {
+		version = path[i+1:]
+		path = path[:i]
+	}
+	parts := r.splitPath(path)
+	if last := parts[len(parts)-1]; len(last) > 1 && last[0] == '*' {
+		parts[len(parts)-1] = "*"
+	}
+	inlineParts(parts)
+	node := r.routes.find(parts, version)
+	if node == nil || len(node.Handler) == 0 {
+		return nil, fmt.Errorf("route not found: %s", path)
+	}
+	return node.methods(), nil
+}
+
+// ValidateRoutes checks that every ":named" segment of the registered routes
+// has a matcher or a non-empty regular expression, an empty regex only
+// matches empty segments so the route is never found. It returns an error per
+// route and segment, nil if all the routes are valid.
+func (r *Router) ValidateRoutes() []error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var errs []error
+	r.routes.walk("", func(path string, node *Trie) {
+		for _, p := range r.splitPath(path) {
+			if !strings.HasPrefix(p, ":") {
+				continue
+			}
+			if _, ok := r.matchers[p]; ok {
+				continue
+			}
+			rx, ok := r.dynamicRoutes[p]
+			switch {
+			case !ok:
+				errs = append(errs, fmt.Errorf("route %q: [%s] not found", path, p))
+			case strings.Trim(rx.String(), "^$") == "", rx.String() == "^(?:)$":
+				errs = append(errs, fmt.Errorf("route %q: [%s] has an empty regex", path, p))
+			}
+		}
+	})
+	return errs
+}
@@ -0,0 +1,223 @@
+package violetear
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRoutesInfo(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	for _, v := range dynamicRoutes {
+		router.AddRegex(v.name, v.regex)
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {}
+	router.HandleFunc("/root/:uuid/item", handler, "POST,PUT")
+	router.HandleFunc("/", handler)
+	router.HandleFunc("*", handler, "GET")
+	router.HandleFunc("/hello/", handler, "GET, HEAD").Name("hello")
+	router.HandleFunc("/hello/", handler, "POST")
+	router.HandleFunc("/static/*", handler, "GET")
+	router.HandleFunc("/root#v2", handler, "GET")
+	router.HandleFunc("/root", handler, "GET")
+	expect(t, router.GetError(), nil)
+
+	expectDeepEqual(t, router.Routes(), []RouteInfo{
+		{Path: "/", Methods: []string{"ALL"}},
+		{Path: "/*", Methods: []string{"GET"}},
+		{Path: "/hello/", Methods: []string{"GET", "HEAD", "POST"}, Name: "hello"},
+		{Path: "/root", Methods: []string{"GET"}},
+		{Path: "/root", Methods: []string{"GET"}, Version: "v2"},
+		{Path: "/root/:uuid/item", Methods: []string{"POST", "PUT"}},
+		{Path: "/static/*", Methods: []string{"GET"}},
+	})
+
+	expect(t, len(New().Routes()), 0)
+}
+
+func TestValidateRoutes(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {}
+
+	// broken regex
+	router := New()
+	router.Verbose = false
+	expect(t, router.AddRegex(":uuid", `[0-9a-f`) != nil, true)
+	expect(t, router.HandleFunc("/root/:uuid", handler) == nil, true)
+
+	router = New()
+	router.Verbose = false
+	expect(t, router.AddRegex(":id", `\d+`), nil)
+	expect(t, router.AddRegex(":empty", ``), nil)
+	expect(t, router.AddMatcher(":name", func(s string) bool { return s != "" }), nil)
+	router.HandleFunc("/users/:id", handler)
+	router.HandleFunc("/users/:id/:name", handler)
+	router.HandleFunc("/items/:empty", handler)
+	router.HandleFunc("/items/:empty/:id", handler)
+	expect(t, router.GetError(), nil)
+
+	errs := router.ValidateRoutes()
+	expect(t, len(errs), 2)
+	expect(t, errs[0].Error(), `route "/items/:empty": [:empty] has an empty regex`)
+	expect(t, errs[1].Error(), `route "/items/:empty/:id": [:empty] has an empty regex`)
+
+	expect(t, len(New().ValidateRoutes()), 0)
+}
+
+func TestConflictingRoutes(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	router := New()
+	router.AddRegex(":id", `\d+`)
+	router.HandleFunc("/users/:id", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("first"))
+	}, "GET")
+	router.HandleFunc("/users/admin", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("admin"))
+	}, "GET")
+	expect(t, strings.Contains(buf.String(), "Conflicting path"), false)
+
+	router.HandleFunc("/users/:id", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("second"))
+	}, "GET")
+	expect(t, strings.Contains(buf.String(), "Conflicting path: /users/:id [GET]"), true)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/users/42", nil)
+	router.ServeHTTP(w, req)
+	expect(t, w.Body.String(), "first")
+
+	buf.Reset()
+	router.Verbose = false
+	router.HandleFunc("/users/:id", func(w http.ResponseWriter, r *http.Request) {}, "GET")
+	expect(t, buf.Len(), 0)
+}
+
+func TestShadowedRoutes(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	handler := func(w http.ResponseWriter, r *http.Request) {}
+	router := New()
+	router.AddRegex(":id", `\d+`)
+	router.AddRegex(":name", `[a-z]+`)
+	router.HandleFunc("/users/:id", handler)
+	router.HandleFunc("/users/admin", handler)
+	expect(t, strings.Contains(buf.String(), "Shadowing path"), false)
+
+	router.HandleFunc("/users/42", handler)
+	expect(t, strings.Contains(buf.String(), `Shadowing path: "42" under /users takes precedence over :id`), true)
+
+	buf.Reset()
+	router.HandleFunc("/users/:name/posts", handler)
+	expect(t, strings.Contains(buf.String(), `Shadowed path: :name under /users never matches "admin"`), true)
+
+	buf.Reset()
+	router.HandleFunc("/users/42#v2", handler)
+	expect(t, strings.Contains(buf.String(), "Shadow"), false)
+}
+
+func TestAllowedMethods(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.AddRegex(":id", `\d+`)
+	handler := func(w http.ResponseWriter, r *http.Request) {}
+	router.HandleFunc("/users/:id", handler, "GET,HEAD")
+	router.HandleFunc("/users/:id", handler, "PUT")
+	router.HandleFunc("/users/:id#v2", handler, "DELETE")
+	router.HandleFunc("/files/*filepath", handler)
+	router.HandleFunc("/", handler, "GET")
+
+	tt := []struct {
+		path    string
+		methods []string
+		err     bool
+	}{
+		{"/users/:id", []string{"GET", "HEAD", "PUT"}, false},
+		{"/users/:id/", []string{"GET", "HEAD", "PUT"}, false},
+		{"/users/:id#v2", []string{"DELETE"}, false},
+		{"/files/*filepath", []string{"ALL"}, false},
+		{"/files/*", []string{"ALL"}, false},
+		{"/", []string{"GET"}, false},
+		{"/users", nil, true},
+		{"/users/1", nil, true},
+		{"/users/:id#v3", nil, true},
+		{"/missing", nil, true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.path, func(t *testing.T) {
+			methods, err := router.AllowedMethods(tc.path)
+			expect(t, err != nil, tc.err)
+			expectDeepEqual(t, methods, tc.methods)
+		})
+	}
+}
+
+func TestHandleRoutes(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.AddRegex(":id", `\d+`)
+	handler := func(body string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body + GetParam("id", r)))
+		})
+	}
+	header := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Route", "users")
+			next.ServeHTTP(w, r)
+		})
+	}
+	errs := router.HandleRoutes([]Route{
+		{Path: "/", Handler: handler("root")},
+		{Path: "/users", Handler: handler("users"), Methods: []string{"GET", "POST"}, Middleware: []func(http.Handler) http.Handler{header}},
+		{Path: "/users/:id", Handler: handler("user "), Methods: []string{"GET"}, Name: "user"},
+		{Path: "/users/:id", Handler: handler("user v2 "), Version: "v2"},
+		{Path: "/posts/:slug", Handler: handler("post")},
+		{Path: "/nil"},
+	})
+	expect(t, len(errs), 2)
+	expect(t, strings.Contains(errs[0].Error(), `route "/posts/:slug": [:slug] not found`), true)
+	expect(t, errs[1].Error(), `route "/nil": nil handler`)
+
+	tt := []struct {
+		method string
+		path   string
+		accept string
+		code   int
+		body   string
+	}{
+		{"GET", "/", "", 200, "root"},
+		{"POST", "/users", "", 200, "users"},
+		{"GET", "/users/1", "", 200, "user 1"},
+		{"DELETE", "/users/1", "", 405, "Method Not Allowed\n"},
+		{"GET", "/users/1", "application/vnd.v2", 200, "user v2 1"},
+		{"GET", "/posts/hello", "", 404, "404 page not found\n"},
+	}
+	for _, tc := range tt {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(tc.method, tc.path, nil)
+		if tc.accept != "" {
+			req.Header.Set("Accept", tc.accept)
+		}
+		router.ServeHTTP(w, req)
+		expect(t, w.Code, tc.code)
+		expect(t, w.Body.String(), tc.body)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/users", nil)
+	router.ServeHTTP(w, req)
+	expect(t, w.Header().Get("X-Route"), "users")
+
+	url, err := router.URL("user", map[string]string{"id": "7"})
+	expect(t, err, nil)
+	expect(t, url, "/users/7")
+}
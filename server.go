@@ -0,0 +1,112 @@
+package violetear
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// ServerOption configures ListenAndServe
+type ServerOption func(*server)
+
+// server settings used by ListenAndServe
+type server struct {
+	srv             *http.Server
+	shutdownTimeout time.Duration
+	ctx             context.Context
+}
+
+// WithShutdownTimeout sets the time to wait for in-flight requests when
+// shutting down, defaults to 30 seconds.
+func WithShutdownTimeout(d time.Duration) ServerOption {
+	return func(s *server) {
+		s.shutdownTimeout = d
+	}
+}
+
+// WithBaseContext sets the parent context of the requests, when it is
+// canceled the context of the in-flight requests is canceled and the server
+// shuts down gracefully like when receiving SIGINT or SIGTERM.
+func WithBaseContext(ctx context.Context) ServerOption {
+	return func(s *server) {
+		s.ctx = ctx
+	}
+}
+
+// WithServer allows to configure the http.Server, example timeouts:
+//
+//	router.ListenAndServe(":8080", violetear.WithServer(func(srv *http.Server) {
+//	    srv.ReadTimeout = 5 * time.Second
+//	}))
+func WithServer(fn func(*http.Server)) ServerOption {
+	return func(s *server) {
+		fn(s.srv)
+	}
+}
+
+// ListenAndServe listens on the TCP network address addr and serves the
+// router until SIGINT or SIGTERM is received or the context set with
+// WithBaseContext is canceled, then shuts down the server
+// gracefully waiting for the in-flight requests to finish or the shutdown
+// timeout to elapse.
+func (r *Router) ListenAndServe(addr string, opts ...ServerOption) error {
+	if addr == "" {
+		addr = ":http"
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+	return r.serve(ln, stop, opts...)
+}
+
+// serve serves the router on ln until stop receives a signal
+func (r *Router) serve(ln net.Listener, stop <-chan os.Signal, opts ...ServerOption) error {
+	s := &server{
+		srv:             &http.Server{Handler: r},
+		shutdownTimeout: 30 * time.Second,
+	}
+	disableGeneralOptionsHandler(s.srv)
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	// a nil channel blocks if there is no base context
+	var done <-chan struct{}
+	if s.ctx != nil {
+		s.srv.BaseContext = func(net.Listener) context.Context {
+			return s.ctx
+		}
+		done = s.ctx.Done()
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case sig := <-stop:
+		if r.Verbose {
+			log.Printf("Received %s, shutting down", sig)
+		}
+	case <-done:
+		if r.Verbose {
+			log.Printf("Context %s, shutting down", s.ctx.Err())
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+	return s.srv.Shutdown(ctx)
+}
@@ -0,0 +1,10 @@
+//go:build !go1.20
+// +build !go1.20
+
+package violetear
+
+import "net/http"
+
+// disableGeneralOptionsHandler does nothing, before Go 1.20 the http.Server
+// always answers "OPTIONS *" requests itself
+func disableGeneralOptionsHandler(srv *http.Server) {}
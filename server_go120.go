@@ -0,0 +1,11 @@
+//go:build go1.20
+// +build go1.20
+
+package violetear
+
+import "net/http"
+
+// disableGeneralOptionsHandler lets the Router answer "OPTIONS *" requests
+func disableGeneralOptionsHandler(srv *http.Server) {
+	srv.DisableGeneralOptionsHandler = true
+}
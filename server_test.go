@@ -0,0 +1,121 @@
+package violetear
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestServeShutdown(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	started := make(chan struct{})
+	router.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("done"))
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan os.Signal, 1)
+	served := make(chan error, 1)
+	var srv *http.Server
+	go func() {
+		served <- router.serve(ln, stop, WithShutdownTimeout(time.Second), WithServer(func(s *http.Server) {
+			srv = s
+		}))
+	}()
+
+	body := make(chan string, 1)
+	go func() {
+		res, err := http.Get("http://" + ln.Addr().String() + "/slow")
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer res.Body.Close()
+		b, _ := ioutil.ReadAll(res.Body)
+		body <- string(b)
+	}()
+
+	<-started
+	stop <- syscall.SIGTERM
+	expect(t, <-served, nil)
+	expect(t, <-body, "done")
+	expect(t, srv.Handler, http.Handler(router))
+
+	// server is closed
+	_, err = http.Get("http://" + ln.Addr().String() + "/slow")
+	expect(t, err != nil, true)
+}
+
+func TestServeShutdownTimeout(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	started := make(chan struct{})
+	router.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(500 * time.Millisecond)
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan os.Signal, 1)
+	served := make(chan error, 1)
+	go func() {
+		served <- router.serve(ln, stop, WithShutdownTimeout(10*time.Millisecond))
+	}()
+	go http.Get("http://" + ln.Addr().String() + "/slow")
+	<-started
+	stop <- os.Interrupt
+	expect(t, <-served, context.DeadlineExceeded)
+}
+
+func TestServeBaseContext(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	started := make(chan struct{})
+	canceled := make(chan error, 1)
+	router.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		select {
+		case <-r.Context().Done():
+			canceled <- r.Context().Err()
+		case <-time.After(time.Second):
+			canceled <- nil
+		}
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- router.serve(ln, make(chan os.Signal), WithBaseContext(ctx))
+	}()
+	go http.Get("http://" + ln.Addr().String() + "/slow")
+	<-started
+	cancel()
+	expect(t, <-canceled, context.Canceled)
+	expect(t, <-served, nil)
+
+	// server is closed
+	_, err = http.Get("http://" + ln.Addr().String() + "/slow")
+	expect(t, err != nil, true)
+}
+
+func TestListenAndServeError(t *testing.T) {
+	router := New()
+	err := router.ListenAndServe("invalid:address:1")
+	expect(t, err != nil, true)
+}
@@ -0,0 +1,25 @@
+package violetear
+
+import (
+	"io"
+	"net/http/httptest"
+)
+
+// Test builds a request, serves it and returns the recorded response, it is
+// meant to be used on unit tests, example:
+//
+//	w := router.Test("GET", "/users?page=2", nil)
+//	w := router.Test("POST", "/users", strings.NewReader(body), "v2")
+//
+// The optional version sets the "Accept: application/vnd.<version>" header,
+// using the AcceptVersionPrefix and AcceptVersionSuffix if set.
+// It panics if target is not a valid request target.
+func (r *Router) Test(method, target string, body io.Reader, version ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, body)
+	if len(version) > 0 && version[0] != "" {
+		req.Header.Set("Accept", r.acceptHeader(version[0]))
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
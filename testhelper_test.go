@@ -0,0 +1,36 @@
+package violetear
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRouterTest(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write([]byte(r.Method + " " + r.URL.Query().Get("q") + " " + string(body)))
+	}, "GET, POST")
+	router.HandleFunc("/echo#v2", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("v2"))
+	})
+
+	w := router.Test("GET", "/echo?q=1", nil)
+	expect(t, w.Code, 200)
+	expect(t, w.Body.String(), "GET 1 ")
+
+	w = router.Test("POST", "/echo", strings.NewReader("hello"))
+	expect(t, w.Body.String(), "POST  hello")
+
+	w = router.Test("GET", "/echo", nil, "v2")
+	expect(t, w.Body.String(), "v2")
+
+	w = router.Test("PUT", "/echo", nil)
+	expect(t, w.Code, 405)
+
+	w = router.Test("GET", "/none", nil)
+	expect(t, w.Code, 404)
+}
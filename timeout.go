@@ -0,0 +1,15 @@
+package violetear
+
+import (
+	"net/http"
+	"time"
+)
+
+// Timeout returns a middleware that responds with 503 Service Unavailable if
+// the handler takes longer than d, the request context is canceled at the
+// deadline and the writes of the handler after it are discarded.
+func Timeout(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.TimeoutHandler(next, d, http.StatusText(http.StatusServiceUnavailable))
+	}
+}
@@ -0,0 +1,46 @@
+package violetear
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	canceled := make(chan bool, 1)
+	router := New()
+	router.Verbose = false
+	router.LogRequests = true
+	var status int
+	router.Logger = func(w *ResponseWriter, r *http.Request) {
+		status = w.Status()
+	}
+	router.HandleWithMiddleware("/slow", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			canceled <- true
+		case <-time.After(time.Second):
+			canceled <- false
+		}
+		w.Write([]byte("slow"))
+	}), []func(http.Handler) http.Handler{Timeout(10 * time.Millisecond)})
+	router.HandleWithMiddleware("/fast", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("fast"))
+	}), []func(http.Handler) http.Handler{Timeout(time.Second)})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/slow", nil)
+	router.ServeHTTP(w, req)
+	expect(t, w.Code, http.StatusServiceUnavailable)
+	expect(t, w.Body.String(), "Service Unavailable")
+	expect(t, status, http.StatusServiceUnavailable)
+	expect(t, <-canceled, true)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/fast", nil)
+	router.ServeHTTP(w, req)
+	expect(t, w.Code, http.StatusOK)
+	expect(t, w.Body.String(), "fast")
+	expect(t, status, http.StatusOK)
+}
@@ -2,7 +2,10 @@ package violetear
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"sort"
 	"strings"
 )
 
@@ -10,29 +13,184 @@ import (
 type MethodHandler struct {
 	Method  string
 	Handler http.Handler
+	// route returned by the Handle call registering the handler
+	route *RouteHandle
 }
 
 // Trie data structure
 type Trie struct {
-	Handler     []MethodHandler
-	HasCatchall bool
-	HasRegex    bool
-	Node        []*Trie
-	name        string
-	path        string
-	version     string
+	Handler       []MethodHandler
+	HasCatchall   bool
+	HasRegex      bool
+	Node          []*Trie
+	catchallName  string
+	dynamic       []*Trie
+	name          string
+	path          string
+	parent        *Trie
+	pattern       string
+	priority      map[string]int
+	router        *Router
+	static        map[nodeKey]*Trie
+	trailingSlash bool
+	version       string
+	weighted      bool
 }
 
-// contains check if path exists on node
-func (t *Trie) contains(path, version string) (*Trie, bool) {
-	for _, n := range t.Node {
+// nodeKey index of the child nodes by path and version
+type nodeKey struct {
+	path, version string
+}
+
+// add appends node to the children, static nodes are indexed by path and
+// version, ":named" and "*" nodes are kept in order in dynamic
+func (t *Trie) add(node *Trie) {
+	node.parent = t
+	t.Node = append(t.Node, node)
+	if node.isDynamic() {
+		t.dynamic = append(t.dynamic, node)
+		return
+	}
+	if t.static == nil {
+		t.static = map[nodeKey]*Trie{}
+	}
+	t.static[nodeKey{node.path, node.version}] = node
+}
+
+// isDynamic returns true for the ":named" and "*" nodes
+func (t *Trie) isDynamic() bool {
+	return strings.HasPrefix(t.path, ":") || t.path == "*"
+}
+
+// del removes node from the children
+func (t *Trie) del(node *Trie) {
+	t.Node = deleteNode(t.Node, node)
+	t.dynamic = deleteNode(t.dynamic, node)
+	if n, ok := t.static[nodeKey{node.path, node.version}]; ok && n == node {
+		delete(t.static, nodeKey{node.path, node.version})
+	}
+}
+
+// deleteNode returns nodes without node
+func deleteNode(nodes []*Trie, node *Trie) []*Trie {
+	for i, n := range nodes {
+		if n == node {
+			return append(nodes[:i], nodes[i+1:]...)
+		}
+	}
+	return nodes
+}
+
+// contains check if path exists on node, fold compares the path
+// case-insensitively if there is no exact match
+func (t *Trie) contains(path, version string, fold bool) (*Trie, bool) {
+	if n, ok := t.static[nodeKey{path, version}]; ok {
+		return n, true
+	}
+	for _, n := range t.dynamic {
 		if n.path == path && n.version == version {
 			return n, true
 		}
 	}
+	if fold {
+		for _, n := range t.Node {
+			if strings.EqualFold(n.path, path) && n.version == version {
+				return n, true
+			}
+		}
+	}
 	return nil, false
 }
 
+// child returns the static node matching path, fold compares the path
+// case-insensitively if there is no exact match
+func (t *Trie) child(path, version string, fold bool) *Trie {
+	if n, ok := t.static[nodeKey{path, version}]; ok {
+		return n
+	}
+	if fold {
+		for _, n := range t.Node {
+			if !n.isDynamic() && strings.EqualFold(n.path, path) && n.version == version {
+				return n
+			}
+		}
+	}
+	return nil
+}
+
+// methods returns the HTTP methods registered on the node
+func (t *Trie) methods() []string {
+	var methods []string
+	seen := map[string]bool{}
+	for _, h := range t.Handler {
+		if !seen[h.Method] {
+			seen[h.Method] = true
+			methods = append(methods, h.Method)
+		}
+	}
+	return methods
+}
+
+// segments returns the path segments from the root to the node
+func (t *Trie) segments() []string {
+	var parts []string
+	for n := t; n.parent != nil; n = n.parent {
+		parts = append([]string{n.path}, parts...)
+	}
+	return parts
+}
+
+// find returns the node registered for the path segments and version or nil
+func (t *Trie) find(path []string, version string) *Trie {
+	node := t
+	for _, p := range path {
+		n, ok := node.contains(p, version, false)
+		if !ok {
+			return nil
+		}
+		node = n
+	}
+	return node
+}
+
+// conflicts returns the methods overlapping with the handlers of the node
+// matching path and version, MethodAll overlaps with every method. Registering
+// the same segments and version with overlapping methods is a conflict, the
+// handler registered first is used. Different segments that may match the
+// same request, "users/admin" and "users/:id", are not conflicts.
+func (t *Trie) conflicts(path []string, method, version string) []string {
+	node := t.find(path, version)
+	if node == nil {
+		return nil
+	}
+	var methods []string
+	for _, m := range splitMethods(method) {
+		for _, h := range node.Handler {
+			if h.Method == m || h.Method == MethodAll || m == MethodAll {
+				methods = append(methods, m)
+				break
+			}
+		}
+	}
+	return methods
+}
+
+// splitMethods returns the upper case methods of the comma separated list,
+// "*" is returned as MethodAll
+func splitMethods(method string) []string {
+	var methods []string
+	for _, m := range strings.Split(method, ",") {
+		switch m = strings.ToUpper(strings.TrimSpace(m)); m {
+		case "":
+			continue
+		case "*":
+			m = MethodAll
+		}
+		methods = append(methods, m)
+	}
+	return methods
+}
+
 // Set adds a node (url part) to the Trie
 func (t *Trie) Set(path []string, handler http.Handler, method, version string) (*Trie, error) {
 	if len(path) == 0 {
@@ -42,14 +200,14 @@ func (t *Trie) Set(path []string, handler http.Handler, method, version string)
 	key := path[0]
 	newpath := path[1:]
 
-	node, ok := t.contains(key, version)
+	node, ok := t.contains(key, version, false)
 
 	if !ok {
 		node = &Trie{
 			path:    key,
 			version: version,
 		}
-		t.Node = append(t.Node, node)
+		t.add(node)
 
 		// check for regex ":"
 		if strings.HasPrefix(key, ":") {
@@ -63,11 +221,8 @@ func (t *Trie) Set(path []string, handler http.Handler, method, version string)
 	}
 
 	if len(newpath) == 0 {
-		methods := strings.FieldsFunc(method, func(c rune) bool {
-			return c == ','
-		})
-		for _, v := range methods {
-			node.Handler = append(node.Handler, MethodHandler{strings.ToUpper(strings.TrimSpace(v)), handler})
+		for _, v := range splitMethods(method) {
+			node.Handler = append(node.Handler, MethodHandler{Method: v, Handler: handler})
 		}
 		return node, nil
 	}
@@ -79,20 +234,112 @@ func (t *Trie) Set(path []string, handler http.Handler, method, version string)
 	return node.Set(newpath, handler, method, version)
 }
 
-// Get returns a node
+// remove deletes the handlers of the methods from the node matching path,
+// all handlers if no methods, only the ones registered by route if not nil,
+// nodes left without handlers and children are removed from the Trie
+func (t *Trie) remove(path []string, methods []string, version string, route *RouteHandle) error {
+	if len(path) == 0 {
+		return errors.New("path cannot be empty")
+	}
+
+	node, ok := t.contains(path[0], version, false)
+	if !ok {
+		return fmt.Errorf("route not found: %s", path[0])
+	}
+
+	if len(path) > 1 {
+		if err := node.remove(path[1:], methods, version, route); err != nil {
+			return err
+		}
+	} else {
+		if len(node.Handler) == 0 {
+			return fmt.Errorf("route not found: %s", path[0])
+		}
+		if len(methods) == 0 && route == nil {
+			node.Handler = nil
+		} else {
+			var handlers []MethodHandler
+			for _, h := range node.Handler {
+				match := len(methods) == 0
+				for _, m := range methods {
+					if h.Method == m {
+						match = true
+						break
+					}
+				}
+				if !match || route != nil && h.route != route {
+					handlers = append(handlers, h)
+				}
+			}
+			if len(handlers) == len(node.Handler) {
+				return fmt.Errorf("method not found: %s", strings.Join(methods, ","))
+			}
+			node.Handler = handlers
+		}
+		if len(node.Handler) == 0 {
+			node.name = ""
+			node.pattern = ""
+			node.trailingSlash = false
+		}
+		node.updatePriority()
+	}
+
+	// remove the node if empty and reset the flags
+	if len(node.Handler) == 0 && len(node.Node) == 0 {
+		t.del(node)
+		t.HasRegex, t.HasCatchall = false, false
+		for _, n := range t.dynamic {
+			if strings.HasPrefix(n.path, ":") {
+				t.HasRegex = true
+			}
+			if n.path == "*" {
+				t.HasCatchall = true
+			}
+		}
+	}
+	t.updatePriority()
+	return nil
+}
+
+// Get returns a node, the key and remaining path, true if the whole path
+// was found
 func (t *Trie) Get(path, version string) (*Trie, string, string, bool) {
+	return t.lookup(path, version, false)
+}
+
+// lookup searches the path recursively on the tree, fold matches the static
+// segments case-insensitively. The path is split on the escaped form and
+// every segment is percent-decoded so "%2F" is part of the segment and not a
+// separator.
+func (t *Trie) lookup(path, version string, fold bool) (*Trie, string, string, bool) {
 	key, path := t.SplitPath(path)
-	// search the key recursively on the tree
-	if node, ok := t.contains(key, version); ok {
+	if k, err := url.PathUnescape(key); err == nil {
+		key = k
+	}
+	if node, ok := t.contains(key, version, fold); ok && !t.outranked(node, version, "") {
 		if path == "" {
 			return node, key, path, true
 		}
-		return node.Get(path, version)
+		return node.lookup(path, version, fold)
 	}
 	// if not fount check for catchall or regex
 	return t, key, path, false
 }
 
+// outranked returns true if node is static and a ":named" sibling has a
+// higher priority for the method
+func (t *Trie) outranked(node *Trie, version, method string) bool {
+	if !t.weighted || node.isDynamic() {
+		return false
+	}
+	for _, n := range t.dynamic {
+		if n.version == version && strings.HasPrefix(n.path, ":") && n.priorityFor(method) > node.priorityFor(method) {
+			return true
+		}
+	}
+	return false
+}
+
 // SplitPath returns first element of path and remaining path
 func (t *Trie) SplitPath(path string) (string, string) {
 	var key string
@@ -119,8 +366,91 @@ func (t *Trie) SplitPath(path string) (string, string) {
 	return path, ""
 }
 
-// Name add custom name to node
+// walk calls fn for every node having handlers with the path rebuilt from
+// the segments
+func (t *Trie) walk(prefix string, fn func(string, *Trie)) {
+	for _, n := range t.Node {
+		path := prefix + "/" + n.path + n.catchallName
+		if n.path == "/" {
+			path = "/"
+		}
+		if len(n.Handler) > 0 {
+			fn(path, n)
+		}
+		n.walk(path, fn)
+	}
+}
+
+// updatePriority sets the priority per method of the node from the weight of
+// its handlers and the priority of its children
+func (t *Trie) updatePriority() {
+	t.priority, t.weighted = nil, false
+	set := func(method string, weight int) {
+		if weight == 0 {
+			return
+		}
+		if t.priority == nil {
+			t.priority = map[string]int{}
+		}
+		if p, ok := t.priority[method]; !ok || weight > p {
+			t.priority[method] = weight
+		}
+	}
+	for _, h := range t.Handler {
+		if h.route != nil {
+			set(h.Method, h.route.weight)
+		}
+	}
+	for _, n := range t.Node {
+		for m, p := range n.priority {
+			set(m, p)
+			t.weighted = true
+		}
+	}
+}
+
+// priorityFor returns the priority of the node for the method, the highest
+// of the method and MethodAll, the highest of all the methods if empty
+func (t *Trie) priorityFor(method string) int {
+	if method == "" {
+		p := 0
+		for _, v := range t.priority {
+			if v > p {
+				p = v
+			}
+		}
+		return p
+	}
+	p := t.priority[method]
+	if all := t.priority[MethodAll]; all > p {
+		return all
+	}
+	return p
+}
+
+// byPriority returns the nodes sorted by their priority for the method
+func byPriority(nodes []*Trie, method string) []*Trie {
+	sorted := make([]*Trie, len(nodes))
+	copy(sorted, nodes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].priorityFor(method) > sorted[j].priorityFor(method)
+	})
+	return sorted
+}
+
+// Name add custom name to node, the nodes returned by Handle also register
+// the name to be used by URL like HandleNamed.
 func (t *Trie) Name(name string) *Trie {
+	if t == nil {
+		return nil
+	}
+	if t.router == nil {
+		t.name = name
+		return t
+	}
+	t.router.mu.Lock()
+	defer t.router.mu.Unlock()
 	t.name = name
+	t.router.names[name] = t.pattern
 	return t
 }
@@ -185,3 +185,91 @@ func TestSplitPath(t *testing.T) {
 		expect(t, p, tc.out[1])
 	}
 }
+
+func TestTrieRemove(t *testing.T) {
+	trie := &Trie{}
+	_, err := trie.Set([]string{"root", ":id"}, nil, "GET,POST", "")
+	expect(t, err, nil)
+	_, err = trie.Set([]string{"root", "*"}, nil, "ALL", "")
+	expect(t, err, nil)
+	_, err = trie.Set([]string{"root", "static"}, nil, "ALL", "")
+	expect(t, err, nil)
+
+	root := trie.Node[0]
+	expect(t, root.HasRegex, true)
+	expect(t, root.HasCatchall, true)
+
+	expect(t, trie.remove([]string{"root", "none"}, nil, "", nil) != nil, true)
+	expect(t, trie.remove([]string{"root", ":id"}, []string{"PUT"}, "", nil) != nil, true)
+	expect(t, trie.remove([]string{"root"}, nil, "", nil) != nil, true)
+
+	expect(t, trie.remove([]string{"root", ":id"}, []string{"POST"}, "", nil), nil)
+	expect(t, len(root.Node), 3)
+	expect(t, root.HasRegex, true)
+
+	expect(t, trie.remove([]string{"root", ":id"}, []string{"GET"}, "", nil), nil)
+	expect(t, len(root.Node), 2)
+	expect(t, root.HasRegex, false)
+	expect(t, root.HasCatchall, true)
+
+	expect(t, trie.remove([]string{"root", "*"}, nil, "", nil), nil)
+	expect(t, root.HasCatchall, false)
+
+	// empty parents are removed
+	expect(t, trie.remove([]string{"root", "static"}, nil, "", nil), nil)
+	expect(t, len(trie.Node), 0)
+}
+
+func TestTrieIndex(t *testing.T) {
+	trie := &Trie{}
+	trie.Set([]string{"a"}, nil, "ALL", "")
+	trie.Set([]string{"a"}, nil, "ALL", "v2")
+	trie.Set([]string{":id"}, nil, "ALL", "")
+	trie.Set([]string{"*"}, nil, "ALL", "")
+	expect(t, len(trie.Node), 4)
+	expect(t, len(trie.static), 2)
+	expect(t, len(trie.dynamic), 2)
+
+	n, ok := trie.contains("a", "v2", false)
+	expect(t, ok, true)
+	expect(t, n.version, "v2")
+	_, ok = trie.contains("A", "", false)
+	expect(t, ok, false)
+	_, ok = trie.contains("A", "", true)
+	expect(t, ok, true)
+
+	expect(t, trie.remove([]string{"a"}, nil, "v2", nil), nil)
+	expect(t, len(trie.static), 1)
+	_, ok = trie.contains("a", "v2", false)
+	expect(t, ok, false)
+}
+
+func TestTrieConflicts(t *testing.T) {
+	trie := &Trie{}
+	trie.Set([]string{"users", ":id"}, nil, "GET,HEAD", "")
+	trie.Set([]string{"users", "admin"}, nil, "ALL", "")
+	trie.Set([]string{"users", ":id"}, nil, "PUT", "v2")
+
+	tt := []struct {
+		name      string
+		path      []string
+		method    string
+		version   string
+		conflicts []string
+	}{
+		{"exact duplicate", []string{"users", ":id"}, "GET", "", []string{"GET"}},
+		{"overlap", []string{"users", ":id"}, "POST, head", "", []string{"HEAD"}},
+		{"ALL", []string{"users", ":id"}, "ALL", "", []string{"ALL"}},
+		{"existing ALL", []string{"users", "admin"}, "DELETE", "", []string{"DELETE"}},
+		{"other method", []string{"users", ":id"}, "POST", "", nil},
+		{"other version", []string{"users", ":id"}, "GET", "v2", nil},
+		{"same version", []string{"users", ":id"}, "PUT", "v2", []string{"PUT"}},
+		{"literal vs :id", []string{"users", "42"}, "GET", "", nil},
+		{"no handlers", []string{"users"}, "GET", "", nil},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			expectDeepEqual(t, trie.conflicts(tc.path, tc.method, tc.version), tc.conflicts)
+		})
+	}
+}
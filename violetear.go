@@ -50,34 +50,209 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
+	"path"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 )
 
-// ParamsKey used for the context
+// ParamsKey and PatternKey used for the context
 const (
 	ParamsKey     key = 0
+	panicStackKey key = 1
+	PatternKey    key = 2
+	requestIDKey  key = 3
+	producesKey   key = 4
 	versionHeader     = "application/vnd."
 )
 
+// MethodAll registers the handler for every method, it is used when no
+// methods are passed to Handle and "*" is a synonym. A handler registered for
+// the request method takes precedence over the MethodAll handler of the same
+// route regardless of the registration order.
+const MethodAll = "ALL"
+
 // key int is unexported to prevent collisions with context keys defined in
 // other packages.
 type key int
 
 // Router struct
 type Router struct {
+	// mu protects the routes, dynamic routes, hosts, names and middleware,
+	// registering takes the write lock and ServeHTTP the read lock only
+	// while looking up the handler, never while serving it, so handlers can
+	// register routes. Host routers share the lock with their parent.
+	mu *sync.RWMutex
+
 	// dynamicRoutes map of dynamic routes and regular expressions
 	dynamicRoutes dynamicSet
 
+	// matchers map of dynamic routes and functions used instead of a regex
+	matchers matcherSet
+
+	// regexCache usage of the dynamic routes, used to bound dynamicRoutes
+	regexCache *regexCache
+
 	// Routes to be matched
 	routes *Trie
 
+	// hosts map of hostnames and their routers
+	hosts map[string]*Router
+
+	// versions registered, sorted from the highest to the lowest
+	versions []string
+
+	// names map of named routes and their paths, used by URL
+	names map[string]string
+
+	// notFoundHandlers handlers used when no route matches under a prefix,
+	// set with Group.NotFound
+	notFoundHandlers []prefixHandler
+
+	// middleware global chain applied to every request
+	middleware []func(http.Handler) http.Handler
+
+	// values added to the context of every request, set with WithValue
+	values []contextValue
+
+	// healthChecks patterns registered with HealthCheck
+	healthChecks map[string]bool
+
 	// Logger
 	Logger func(*ResponseWriter, *http.Request)
 
+	// LogHandler receives a LogEntry per request when LogRequests is true, if
+	// set it is used instead of Logger.
+	LogHandler func(LogEntry)
+
 	// LogRequests yes or no
 	LogRequests bool
 
+	// LogOnlyErrors log only the requests with a 4xx or 5xx status code and
+	// the ones that panicked.
+	LogOnlyErrors bool
+
+	// LogSampleRate fraction of the requests to log between 0 and 1, 0
+	// (default) logs every request. The panics and, if LogOnlyErrors is true,
+	// the errors are always logged.
+	LogSampleRate float64
+
+	// logSample returns the random number in [0, 1) compared against the
+	// LogSampleRate, rand.Float64 if nil
+	logSample func() float64
+
+	// LogHealthCheck log the requests to the HealthCheck routes, they are
+	// skipped by default to avoid noise.
+	LogHealthCheck bool
+
+	// MetricsHook called after each request with the registered pattern of
+	// the matched route (empty if no route matched), the method, the status
+	// code and the duration of the request.
+	MetricsHook func(pattern string, method string, status int, duration time.Duration)
+
+	// TrustProxy use the client address of the X-Forwarded-For or X-Real-IP
+	// headers as the request RemoteAddr, for the loggers and RateLimit, when
+	// the request comes from a proxy. Any peer is trusted, use
+	// TrustedProxies to trust only some of them.
+	TrustProxy bool
+
+	// TrustedProxies networks of the trusted proxies, the addresses of
+	// X-Forwarded-For in these networks are skipped to find the client.
+	TrustedProxies []net.IPNet
+
+	// AutoHead respond to HEAD requests using the GET handler, when no HEAD
+	// handler is registered for the path, the body is discarded.
+	AutoHead bool
+
+	// RedirectTrailingSlash redirect requests to the path registered form when
+	// only the trailing slash differs, "/hello" -> "/hello/" if the route was
+	// registered as "/hello/" and vice versa. GET and HEAD requests are
+	// redirected using 301, other methods 308. The trailing slash is not used
+	// to find the route, when false "/hello" and "/hello/" are handled by the
+	// same route without redirecting unless StrictSlash is true.
+	RedirectTrailingSlash bool
+
+	// StrictSlash only match the trailing slash form of the registered path,
+	// "/hello/" returns 404 if the route was registered as "/hello" and vice
+	// versa. It is false by default, both forms are handled by the same
+	// route, and it has no effect if RedirectTrailingSlash is true since the
+	// other form is redirected instead. "/hello" and "/hello/" are the same
+	// route, they can't be registered with different handlers.
+	StrictSlash bool
+
+	// GreedyCatchall set the "*" param to the whole remaining path,
+	// "/static/*" gets "css/app.css" for "/static/css/app.css" instead of
+	// only the first segment "css".
+	GreedyCatchall bool
+
+	// CatchallFallback use the catch-all of a level when the routes below
+	// the static or ":named" node matching the segment don't match the rest
+	// of the path, "/api/*" handles "/api/v1/other" if only "/api/v1/users"
+	// is registered. By default a segment matching a static or ":named" node
+	// is never retried against the catch-all of the same level.
+	CatchallFallback bool
+
+	// CleanPath redirect GET and HEAD requests to the canonical path using
+	// 301, "//a/./b/../c" -> "/a/c", the trailing slash is kept.
+	CleanPath bool
+
+	// CaseInsensitive match the static path segments ignoring case, the
+	// ":named" regex and the catch-all still get the original value.
+	CaseInsensitive bool
+
+	// QueryParams add the query string values to the Params, only the first
+	// value of each key is used and path params take precedence.
+	QueryParams bool
+
+	// DisableVersioning don't use the Accept header to set the version, the
+	// requests are dispatched to the unversioned routes unless VersionParam
+	// or VersionFromPath are set.
+	DisableVersioning bool
+
+	// AcceptVersionPrefix media type prefix of the Accept header preceding
+	// the version, defaults to "application/vnd.", with
+	// "application/vnd.myapp.v" the header "application/vnd.myapp.v2+json"
+	// dispatches the route registered as "/users#2".
+	AcceptVersionPrefix string
+
+	// AcceptVersionSuffix removed from the version of the Accept header after
+	// the structured syntax suffix "+json", which is always removed.
+	AcceptVersionSuffix string
+
+	// VersionFromPath use the first path segment matching v[0-9]+ as the
+	// version, "/v2/users" dispatches the route registered as "/users#v2",
+	// it takes precedence over the Accept header.
+	VersionFromPath bool
+
+	// VersionParam name of the query parameter used to set the version,
+	// "/users?api_version=2" dispatches the route registered as "/users#2",
+	// it takes precedence over the Accept header, an empty or missing value
+	// falls back to it.
+	VersionParam string
+
+	// FallbackToUnversioned dispatch requests without version that don't
+	// match an unversioned route using the highest registered version.
+	FallbackToUnversioned bool
+
+	// DefaultVersion used when the requested version has no route for the
+	// path, requests without version keep using the unversioned routes.
+	DefaultVersion string
+
+	// MethodOverrideHeader name of the header used to override the method of
+	// POST requests, "X-HTTP-Method-Override: DELETE" dispatches the DELETE
+	// handler, the "_method" field of urlencoded forms is used if the header
+	// is not present.
+	MethodOverrideHeader string
+
+	// AutoOptions respond to OPTIONS requests with 204 and the Allow header
+	// when no OPTIONS handler is registered for the path.
+	AutoOptions bool
+
 	// NotFoundHandler configurable http.Handler which is called when no matching
 	// route is found. If it is not set, http.NotFound is used.
 	NotFoundHandler http.Handler
@@ -85,10 +260,36 @@ type Router struct {
 	// NotAllowedHandler configurable http.Handler which is called when method not allowed.
 	NotAllowedHandler http.Handler
 
+	// ErrorHandler called with the error returned by the handlers registered
+	// with HandleError.
+	ErrorHandler func(http.ResponseWriter, *http.Request, error)
+
 	// PanicHandler function to handle panics.
 	PanicHandler http.HandlerFunc
 
-	// RequestID name of the header to use or create.
+	// PanicHandlerWithError function to handle panics receiving the recovered
+	// value, the stack trace is available with GetPanicStack. If set it is used
+	// instead of PanicHandler.
+	PanicHandlerWithError func(http.ResponseWriter, *http.Request, interface{})
+
+	// PanicResponse body of the 500 response sent when a handler panics and
+	// neither PanicHandler nor PanicHandlerWithError is set, if nil
+	// "Internal Server Error" is used.
+	PanicResponse []byte
+
+	// PanicContentType Content-Type of the PanicResponse, defaults to
+	// "text/plain; charset=utf-8".
+	PanicContentType string
+
+	// LogPanicStack log the stack trace of the goroutine when a handler
+	// panics, before calling the PanicHandler. When LogRequests is true the
+	// stack is passed to the Logger (ResponseWriter.Stack) or the LogHandler
+	// (LogEntry.Stack) instead.
+	LogPanicStack bool
+
+	// RequestID name of the header to use or create, if the request doesn't
+	// have it a UUID is generated. The ID is set on the response header and
+	// on the request context, see RequestIDFromContext.
 	RequestID string
 
 	// Verbose
@@ -101,58 +302,523 @@ type Router struct {
 // New returns a new initialized router.
 func New() *Router {
 	return &Router{
+		mu:            &sync.RWMutex{},
 		dynamicRoutes: dynamicSet{},
+		matchers:      matcherSet{},
+		regexCache:    newRegexCache(),
 		routes:        &Trie{},
+		names:         map[string]string{},
 		Logger:        logger,
 		Verbose:       true,
 	}
 }
 
 // Handle registers the handler for the given pattern (path, http.Handler, methods).
-func (r *Router) Handle(path string, handler http.Handler, httpMethods ...string) *Trie {
+// The last segment can be optional using "?", "/posts/:page?" registers the
+// handler for both "/posts" and "/posts/:page". The catch-all can be named,
+// "/files/*filepath" adds the whole remaining path to the "filepath" param.
+// The regex can be set inline, "/user/{id:[0-9]+}" adds the "id" param
+// without calling AddRegex.
+func (r *Router) Handle(path string, handler http.Handler, httpMethods ...string) *RouteHandle {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	var version string
 	if i := strings.Index(path, "#"); i != -1 {
 		version = path[i+1:]
 		path = path[:i]
 	}
+	route := &RouteHandle{router: r, paths: []string{path}, handler: handler}
 	pathParts := r.splitPath(path)
 
+	// optional last segment
+	var optional bool
+	for i, p := range pathParts {
+		if strings.HasSuffix(p, "?") {
+			if i != len(pathParts)-1 || p == "?" || p == "*?" {
+				r.err = fmt.Errorf("optional segment %q must be the last path element", p)
+				return nil
+			}
+			optional = true
+			pathParts[i] = strings.TrimSuffix(p, "?")
+			path = strings.Replace(path, p, pathParts[i], 1)
+		}
+	}
+
+	// named catch-all
+	var catchallName string
+	for i, p := range pathParts {
+		if len(p) > 1 && p[0] == '*' {
+			if i != len(pathParts)-1 {
+				r.err = fmt.Errorf("catch-all %q must always be the final path element", p)
+				return nil
+			}
+			catchallName = p[1:]
+			pathParts[i] = "*"
+		}
+	}
+
+	// inline regex constraints, "{id:[0-9]+}"
+	for i, p := range pathParts {
+		if name, regex, ok := inlineRegex(p); ok {
+			if _, ok := r.dynamicRoutes[name]; !ok {
+				if err := r.dynamicRoutes.Set(name, regex); err != nil {
+					r.err = err
+					return nil
+				}
+			}
+			pathParts[i] = name
+		}
+	}
+
 	// search for dynamic routes
 	for _, p := range pathParts {
 		if strings.HasPrefix(p, ":") {
-			if _, ok := r.dynamicRoutes[p]; !ok {
+			_, ok := r.dynamicRoutes[p]
+			if _, ok2 := r.matchers[p]; !ok && !ok2 {
 				r.err = fmt.Errorf("[%s] not found, need to add it using AddRegex(%q, `your regex`", p, p)
 				return nil
 			}
 		}
 	}
 
-	// if no methods, accept ALL
-	methods := "ALL"
-	if len(httpMethods) > 0 && len(strings.TrimSpace(httpMethods[0])) > 0 {
-		methods = httpMethods[0]
+	// methods can be passed as "GET,HEAD" or "GET", "HEAD", if no methods,
+	// accept ALL
+	methods := MethodAll
+	if m := strings.Trim(strings.Join(httpMethods, ","), ", "); m != "" {
+		methods = m
 	}
 
 	if r.Verbose {
 		log.Printf("Adding path: %s [%s] %s", path, methods, version)
+		if c := r.routes.conflicts(pathParts, methods, version); len(c) > 0 {
+			log.Printf("Conflicting path: %s [%s] %s already registered, using the first handler", path, strings.Join(c, ","), version)
+		}
+		r.warnShadowed(pathParts, version)
 	}
+	route.methods, route.optional = methods, optional
 
+	if optional {
+		short := pathParts[:len(pathParts)-1]
+		if len(short) == 0 {
+			short = []string{"/"}
+		}
+		before := r.handlers(short, version)
+		node, err := r.routes.Set(short, handler, methods, version)
+		if err != nil {
+			r.err = err
+			return nil
+		}
+		if node.pattern == "" {
+			node.pattern = "/" + strings.Join(short, "/")
+			if short[0] == "/" {
+				node.pattern = "/"
+			}
+		}
+		node.router = r
+		r.refRegex(short, len(node.Handler)-before)
+		route.own(node, before)
+	}
+
+	before := r.handlers(pathParts, version)
 	trie, err := r.routes.Set(pathParts, handler, methods, version)
 	if err != nil {
 		r.err = err
 		return nil
 	}
-	return trie
+	if version != "" {
+		r.addVersion(version)
+	}
+	trie.trailingSlash = len(path) > 1 && strings.HasSuffix(path, "/")
+	if catchallName != "" {
+		trie.catchallName = catchallName
+	}
+	if trie.pattern == "" {
+		trie.pattern = path
+	}
+	trie.router = r
+	r.refRegex(pathParts, len(trie.Handler)-before)
+	route.own(trie, before)
+	route.Trie = trie
+	return route
+}
+
+// handlers returns the number of handlers registered for the path parts and
+// version
+func (r *Router) handlers(parts []string, version string) int {
+	if node := r.routes.find(parts, version); node != nil {
+		return len(node.Handler)
+	}
+	return 0
+}
+
+// refRegex references the ":named" regular expressions of the path parts n
+// times, once per method handler, a negative n drops the references
+func (r *Router) refRegex(parts []string, n int) {
+	for _, p := range parts {
+		if !strings.HasPrefix(p, ":") {
+			continue
+		}
+		for i := 0; i < n; i++ {
+			r.regexCache.ref(p)
+		}
+		for i := 0; i > n; i-- {
+			r.regexCache.unref(p)
+		}
+	}
+}
+
+// warnShadowed logs the literal segments of parts matching a ":named" sibling
+// and vice versa, the literal segment always takes precedence so the
+// ":named" route never receives that value.
+func (r *Router) warnShadowed(parts []string, version string) {
+	node := r.routes
+	for i, p := range parts {
+		prefix := "/" + strings.Join(parts[:i], "/")
+		for _, n := range node.Node {
+			if n.version != version || n.path == p || n.path == "*" {
+				continue
+			}
+			switch {
+			case strings.HasPrefix(n.path, ":") && !strings.HasPrefix(p, ":") && p != "*" && r.matchDynamic(n.path, p):
+				log.Printf("Shadowing path: %q under %s takes precedence over %s", p, prefix, n.path)
+			case strings.HasPrefix(p, ":") && !strings.HasPrefix(n.path, ":") && r.matchDynamic(p, n.path):
+				log.Printf("Shadowed path: %s under %s never matches %q", p, prefix, n.path)
+			}
+		}
+		next, ok := node.contains(p, version, false)
+		if !ok {
+			return
+		}
+		node = next
+	}
 }
 
 // HandleFunc add a route to the router (path, http.HandlerFunc, methods)
-func (r *Router) HandleFunc(path string, handler http.HandlerFunc, httpMethods ...string) *Trie {
+func (r *Router) HandleFunc(path string, handler http.HandlerFunc, httpMethods ...string) *RouteHandle {
+	return r.Handle(path, handler, httpMethods...)
+}
+
+// RemoveRoute removes the handlers of the methods from the path, all the
+// handlers if no methods, after removing the route requests to the path
+// return 404 or 405 if handlers for other methods remain.
+func (r *Router) RemoveRoute(path string, httpMethods ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var version string
+	if i := strings.Index(path, "#"); i != -1 {
+		version = path[i+1:]
+		path = path[:i]
+	}
+	pathParts := r.splitPath(path)
+	if last := pathParts[len(pathParts)-1]; len(last) > 1 && last[0] == '*' {
+		pathParts[len(pathParts)-1] = "*"
+	}
+	inlineParts(pathParts)
+
+	methods := splitMethods(strings.Join(httpMethods, ","))
+
+	if r.Verbose {
+		log.Printf("Removing path: %s %v %s", path, methods, version)
+	}
+
+	if err := r.removeHandlers(pathParts, methods, version, nil); err != nil {
+		return err
+	}
+
+	// drop the names if no version of the path has handlers left
+	found := false
+	r.routes.walk("", func(_ string, n *Trie) {
+		if n.pattern == path {
+			found = true
+		}
+	})
+	if !found {
+		for name, p := range r.names {
+			if p == path {
+				delete(r.names, name)
+			}
+		}
+	}
+	return nil
+}
+
+// removeHandlers removes the handlers of the path parts like Trie.remove and
+// drops their references to the regular expressions
+func (r *Router) removeHandlers(parts, methods []string, version string, route *RouteHandle) error {
+	before := r.handlers(parts, version)
+	if err := r.routes.remove(parts, methods, version, route); err != nil {
+		return err
+	}
+	r.refRegex(parts, r.handlers(parts, version)-before)
+	return nil
+}
+
+// HandleNamed registers the handler like Handle and stores the path under
+// name so that it can be reversed later using URL.
+func (r *Router) HandleNamed(name, path string, handler http.Handler, httpMethods ...string) *RouteHandle {
+	trie := r.Handle(path, handler, httpMethods...)
+	return trie.Name(name)
+}
+
+// URL returns the path of the named route, the ":named" segments are
+// replaced with the values in params (keys with or without the ":" prefix)
+// and validated against their regex, the catch-all uses the "*" key or its
+// name. An optional last segment is omitted if its param is missing.
+func (r *Router) URL(name string, params map[string]string) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	path, ok := r.names[name]
+	if !ok {
+		return "", fmt.Errorf("route %q not found", name)
+	}
+	parts := r.splitPath(path)
+	if parts[0] == "/" {
+		return "/", nil
+	}
+	last := len(parts) - 1
+	optional := strings.HasSuffix(parts[last], "?")
+	parts[last] = strings.TrimSuffix(parts[last], "?")
+	inlineParts(parts)
+
+	// the optional last segment is omitted if its param is missing
+	if optional {
+		key := paramKey(parts[last])
+		if _, ok := params[key]; !ok {
+			if _, ok := params[key[1:]]; !ok {
+				parts = parts[:last]
+			}
+		}
+	}
+	for i, p := range parts {
+		switch {
+		case strings.HasPrefix(p, ":"):
+			key := paramKey(p)
+			value, ok := params[key]
+			if !ok {
+				if value, ok = params[key[1:]]; !ok {
+					return "", fmt.Errorf("missing param %q for route %q", key, name)
+				}
+			}
+			if fn, ok := r.matchers[p]; ok {
+				if !fn(value) {
+					return "", fmt.Errorf("param %q value %q does not match", p, value)
+				}
+			} else if rx, ok := r.dynamicRoutes[p]; ok && !rx.MatchString(value) {
+				return "", fmt.Errorf("param %q value %q does not match %s", p, value, rx)
+			}
+			parts[i] = url.PathEscape(value)
+		case strings.HasPrefix(p, "*"):
+			value, ok := params[p]
+			if !ok && p != "*" {
+				if value, ok = params[p[1:]]; !ok {
+					value, ok = params["*"]
+				}
+			}
+			if !ok {
+				return "", fmt.Errorf("missing param %q for route %q", p, name)
+			}
+			parts[i] = strings.TrimLeft(value, "/")
+		}
+	}
+	return "/" + strings.Join(parts, "/"), nil
+}
+
+// HandleWithMiddleware registers the handler wrapped with the given middleware,
+// the route middleware runs after the global Use() middleware and before the
+// handler: mw[0](mw[1](handler)).
+func (r *Router) HandleWithMiddleware(path string, handler http.Handler, mw []func(http.Handler) http.Handler, httpMethods ...string) *RouteHandle {
+	for i := len(mw) - 1; i >= 0; i-- {
+		handler = mw[i](handler)
+	}
 	return r.Handle(path, handler, httpMethods...)
 }
 
-// AddRegex adds a ":named" regular expression to the dynamicRoutes
+// ChainHandler is a handler of HandleChain, it returns the request passed to
+// the next handler, nil to pass the same one
+type ChainHandler func(w http.ResponseWriter, r *http.Request) *http.Request
+
+// HandleChain registers the handlers to run in order until one of them writes
+// the response, a handler can pass values to the next ones returning the
+// request with a new context:
+//
+//	return r.WithContext(context.WithValue(r.Context(), "user", user))
+func (r *Router) HandleChain(path string, handlers []ChainHandler, httpMethods ...string) *RouteHandle {
+	if len(handlers) == 0 {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.err = fmt.Errorf("no handlers for path %q", path)
+		return nil
+	}
+	chain := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ww := NewResponseWriter(w, "")
+		for _, h := range handlers {
+			if next := h(ww, req); next != nil {
+				req = next
+			}
+			if ww.wroteHeader {
+				return
+			}
+		}
+	})
+	return r.Handle(path, chain, httpMethods...)
+}
+
+// Redirect registers a handler redirecting from to the to path using code,
+// which must be a 3xx status. The ":named" and "*" segments of to are
+// replaced with the values captured by from, "/u/:id" -> "/users/:id", the
+// query string is kept.
+func (r *Router) Redirect(from, to string, code int, httpMethods ...string) error {
+	if code < 300 || code > 399 {
+		return fmt.Errorf("invalid redirect code %d", code)
+	}
+	parts := strings.Split(to, "/")
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		seen := map[string]int{}
+		target := make([]string, len(parts))
+		for i, p := range parts {
+			target[i] = p
+			if strings.HasPrefix(p, ":") || strings.HasPrefix(p, "*") {
+				name := strings.TrimPrefix(p, ":")
+				if len(p) > 1 && p[0] == '*' {
+					name = p[1:]
+				}
+				target[i] = GetParam(name, req, seen[p])
+				if p[0] != '*' {
+					target[i] = url.PathEscape(target[i])
+				}
+				seen[p]++
+			}
+		}
+		location := strings.Join(target, "/")
+		if req.URL.RawQuery != "" {
+			location += "?" + req.URL.RawQuery
+		}
+		http.Redirect(w, req, location, code)
+	}
+	if r.HandleFunc(from, handler, httpMethods...) == nil {
+		return r.GetError()
+	}
+	return nil
+}
+
+// Use appends middleware to the global chain. Middleware are applied in
+// registration order, Use(m1, m2) is equivalent to m1(m2(handler)), and they
+// wrap every dispatched handler including NotFoundHandler and
+// NotAllowedHandler. Params are already in the request context when the
+// chain runs, so a middleware can inspect them or short-circuit the request.
+func (r *Router) Use(mw ...func(http.Handler) http.Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.middleware = append(r.middleware, mw...)
+}
+
+// Handler returns the Router as an http.Handler to be wrapped with outer
+// middleware or mounted on another Router. The Use middleware is not applied
+// here but in ServeHTTP after matching the route, so it always runs with the
+// Params in the context and middleware added later are also applied.
+func (r *Router) Handler() http.Handler {
+	return r
+}
+
+// contextValue key and value added to the request context
+type contextValue struct {
+	key, val interface{}
+}
+
+// WithValue adds the key k and val to the context of every request before
+// dispatching it, multiple calls accumulate and the last value of a key wins.
+// The key should be of an unexported type like with context.WithValue, it
+// panics if k is nil or one of the router keys like ParamsKey.
+func (r *Router) WithValue(k, val interface{}) {
+	if k == nil {
+		panic("violetear: nil key")
+	}
+	if _, ok := k.(key); ok {
+		panic("violetear: reserved key")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.values = append(r.values, contextValue{k, val})
+}
+
+// chain wraps the handler with the global middleware
+func (r *Router) chain(h http.Handler) http.Handler {
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		h = r.middleware[i](h)
+	}
+	return h
+}
+
+// AddRegex adds a ":named" regular expression to the dynamicRoutes, an
+// error is returned if the regular expression does not compile. The regex
+// must match the whole path segment, `\d+` doesn't match "12abc", unless it
+// starts with "^", `^\d+` matches "12abc" since only the start is anchored.
 func (r *Router) AddRegex(name, regex string) error {
-	return r.dynamicRoutes.Set(name, regex)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.dynamicRoutes.Set(name, regex); err != nil {
+		return err
+	}
+	r.regexCache.add(name)
+	r.regexCache.evict(r.dynamicRoutes)
+	return nil
+}
+
+// AddRegexes adds the ":named" regular expressions of m like AddRegex, the
+// ones that compile are added and an error is returned for every other one,
+// sorted by name.
+func (r *Router) AddRegexes(m map[string]string) []error {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var errs []error
+	for _, name := range names {
+		if err := r.AddRegex(name, m[name]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// AddMatcher adds a ":named" function to validate the path segment, it is
+// used instead of a regular expression, example:
+//
+//	router.AddMatcher(":id", func(s string) bool {
+//	    _, err := strconv.Atoi(s)
+//	    return err == nil
+//	})
+//
+// If a regular expression with the same name exists, the matcher is used.
+func (r *Router) AddMatcher(name string, fn func(string) bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.matchers.Set(name, fn)
+}
+
+// matchDynamic returns true if value matches the ":named" matcher or regex
+func (r *Router) matchDynamic(name, value string) bool {
+	if fn, ok := r.matchers[name]; ok {
+		return fn(value)
+	}
+	if rx, ok := r.dynamicRoutes[name]; ok {
+		return rx.MatchString(value)
+	}
+	return false
+}
+
+// SetRegexCacheSize bounds the number of regular expressions kept in the
+// dynamicRoutes, the least recently added ones not used by any route are
+// evicted, regular expressions used by a route are always kept. Use 0 for no
+// limit (default).
+func (r *Router) SetRegexCacheSize(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.regexCache.size = n
+	r.regexCache.evict(r.dynamicRoutes)
 }
 
 // MethodNotAllowed default handler for 405
@@ -165,133 +831,668 @@ func (r *Router) MethodNotAllowed() http.HandlerFunc {
 	})
 }
 
-// checkMethod check if request method is allowed or not
-func (r *Router) checkMethod(node *Trie, method string) http.Handler {
+// methodHandler returns the handler of the node for the method including
+// AutoHead and AutoOptions or nil if the method is not allowed, the handler
+// registered for the method is preferred over the MethodAll handler
+func (r *Router) methodHandler(node *Trie, method string) http.Handler {
+	var all http.Handler
 	for _, h := range node.Handler {
-		if h.Method == "ALL" {
-			return h.Handler
-		}
 		if h.Method == method {
 			return h.Handler
 		}
+		if h.Method == MethodAll && all == nil {
+			all = h.Handler
+		}
 	}
-	if r.NotAllowedHandler != nil {
-		return r.NotAllowedHandler
+	if all != nil {
+		return all
 	}
-	return r.MethodNotAllowed()
+	if method == http.MethodHead && r.AutoHead {
+		for _, h := range node.Handler {
+			if h.Method == http.MethodGet {
+				return headHandler(h.Handler)
+			}
+		}
+	}
+	if method == http.MethodOptions && r.AutoOptions && len(node.Handler) > 0 {
+		allow := r.allow(node)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Allow", allow)
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+	return nil
 }
 
-// dispatch request
-func (r *Router) dispatch(node *Trie, key, path, method, version string, leaf bool, params Params) (http.Handler, Params) {
-	catchall := false
-	if node.name != "" {
-		if params == nil {
-			params = Params{}
+// checkMethod check if request method is allowed or not
+func (r *Router) checkMethod(node *Trie, method string) http.Handler {
+	if h := r.methodHandler(node, method); h != nil {
+		return h
+	}
+	allow := r.allow(node)
+	notAllowed := r.NotAllowedHandler
+	if notAllowed == nil {
+		notAllowed = r.MethodNotAllowed()
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", allow)
+		notAllowed.ServeHTTP(w, r)
+	})
+}
+
+// allow returns the value of the Allow header for the node, HEAD and
+// OPTIONS are included when handled by AutoHead and AutoOptions
+func (r *Router) allow(node *Trie) string {
+	methods := node.methods()
+	var hasGet, hasHead, hasOptions bool
+	for _, m := range methods {
+		switch m {
+		case http.MethodGet:
+			hasGet = true
+		case http.MethodHead:
+			hasHead = true
+		case http.MethodOptions:
+			hasOptions = true
 		}
-		params.Add("rname", node.name)
 	}
-	if len(node.Handler) > 0 && leaf {
-		return r.checkMethod(node, method), params
-	} else if node.HasRegex {
-		for _, n := range node.Node {
-			if strings.HasPrefix(n.path, ":") {
-				rx := r.dynamicRoutes[n.path]
-				if rx.MatchString(key) {
-					// add param to context
-					if params == nil {
-						params = Params{}
-					}
-					params.Add(n.path, key)
-					node, key, path, leaf := node.Get(n.path+path, version)
-					return r.dispatch(node, key, path, method, version, leaf, params)
+	if r.AutoHead && hasGet && !hasHead {
+		methods = append(methods, http.MethodHead)
+	}
+	if r.AutoOptions && !hasOptions {
+		methods = append(methods, http.MethodOptions)
+	}
+	return strings.Join(methods, ", ")
+}
+
+// optionsAsterisk returns the handler for "OPTIONS *" requests responding
+// 200 with the Allow header of all the methods of the registered routes, the
+// MethodAll handlers allow all the standard methods. http.Server answers
+// these requests unless DisableGeneralOptionsHandler is true, ListenAndServe
+// sets it when built with Go 1.20 or later.
+func (r *Router) optionsAsterisk() http.Handler {
+	seen := map[string]bool{http.MethodOptions: true}
+	r.routes.walk("", func(path string, node *Trie) {
+		for _, m := range node.methods() {
+			if m == MethodAll {
+				for _, m := range []string{http.MethodGet, http.MethodHead,
+					http.MethodPost, http.MethodPut, http.MethodPatch,
+					http.MethodDelete} {
+					seen[m] = true
 				}
+				continue
 			}
+			seen[m] = true
 		}
-		if node.HasCatchall {
-			catchall = true
+	})
+	if r.AutoHead && seen[http.MethodGet] {
+		seen[http.MethodHead] = true
+	}
+	methods := make([]string, 0, len(seen))
+	for m := range seen {
+		methods = append(methods, m)
+	}
+	sort.Strings(methods)
+	allow := strings.Join(methods, ", ")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", allow)
+		w.Header().Set("Content-Length", "0")
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// match queries the path and dispatches the request
+func (r *Router) match(path, method, version string) (http.Handler, Params, *Trie) {
+	m := matcher{router: r, method: method, version: version}
+	node, pairs := m.dispatch(r.routes, path)
+	if node == nil {
+		node, pairs = m.notAllowed, m.notAllowedPairs
+	}
+	if node == nil {
+		return r.notFound(), nil, nil
+	}
+	params := make(Params, len(pairs)/2+1)
+	for i := 0; i < len(pairs); i += 2 {
+		params.Add(pairs[i], pairs[i+1])
+	}
+	if node.name != "" {
+		params.Add("rname", node.name)
+	}
+	return r.checkMethod(node, method), params, node
+}
+
+// matcher holds the state of a request being dispatched, the params are kept
+// as key/value pairs of the current candidate and the first node matching the
+// path but not the method (405) with its params
+type matcher struct {
+	router          *Router
+	method          string
+	version         string
+	pairs           []string
+	notAllowed      *Trie
+	notAllowedPairs []string
+}
+
+// dispatch searches path below node trying the static segment, the ":named"
+// segments and the catch-all in order of precedence. A static or ":named"
+// candidate whose rest of the path doesn't match falls back to the next one,
+// the catch-all is only used if no candidate matched the segment unless
+// CatchallFallback is set. The node allowing the method and its params are
+// returned, nil if none.
+func (m *matcher) dispatch(node *Trie, path string) (*Trie, []string) {
+	key, rest := node.SplitPath(path)
+	if k, err := url.PathUnescape(key); err == nil {
+		key = k
+	}
+	mark := len(m.pairs)
+
+	// a static node is tried before the ":named" siblings unless they have
+	// a higher priority
+	static := node.child(key, m.version, m.router.CaseInsensitive)
+	matched := static != nil
+	if static != nil && !node.outranked(static, m.version, m.method) {
+		if match := m.next(static, rest); match != nil || m.exact(static, rest) {
+			return match, m.pairs
 		}
-	} else if node.HasCatchall {
-		catchall = true
+		static = nil
 	}
-	if catchall {
-		for _, n := range node.Node {
-			if n.path == "*" {
-				// add "*" to context
-				if params == nil {
-					params = Params{}
+
+	// the catch-all is used if nothing matched the path or to allow the
+	// method of a ":named" node (405)
+	notAllowed, dynamicNotAllowed := m.notAllowed, false
+	if node.HasRegex {
+		dynamic := node.dynamic
+		if node.weighted {
+			dynamic = byPriority(dynamic, m.method)
+		}
+		for _, n := range dynamic {
+			if n.version != m.version || !strings.HasPrefix(n.path, ":") {
+				continue
+			}
+			if static != nil && static.priorityFor(m.method) >= n.priorityFor(m.method) {
+				if match := m.next(static, rest); match != nil || m.exact(static, rest) {
+					return match, m.pairs
 				}
-				params.Add("*", key)
-				if n.name != "" {
-					params.Add("rname", n.name)
+				static = nil
+			}
+			if !m.router.matchDynamic(n.path, key) {
+				continue
+			}
+			matched = true
+			if m.pairs == nil {
+				m.pairs = make([]string, 0, 8)
+			}
+			name := paramKey(n.path)
+			m.pairs = append(m.pairs, name, key)
+			if rx, ok := m.router.dynamicRoutes[n.path]; ok {
+				m.pairs = subexpParams(m.pairs, name, rx, key)
+			}
+			if match := m.next(n, rest); match != nil {
+				return match, m.pairs
+			}
+			m.pairs = m.pairs[:mark]
+			if notAllowed == nil && m.notAllowed != nil {
+				dynamicNotAllowed = true
+			}
+		}
+	}
+	if static != nil {
+		if match := m.next(static, rest); match != nil || m.exact(static, rest) {
+			return match, m.pairs
+		}
+	}
+
+	fallback := m.notAllowed == nil && (!matched || m.router.CatchallFallback)
+	if node.HasCatchall && (fallback || dynamicNotAllowed) {
+		for _, n := range node.dynamic {
+			if n.path != "*" || n.version != m.version {
+				continue
+			}
+			if (m.router.GreedyCatchall || n.catchallName != "") && rest != "" {
+				if p, err := url.PathUnescape(rest); err == nil {
+					rest = p
 				}
-				return r.checkMethod(n, method), params
+				key += rest
 			}
+			m.pairs = append(m.pairs, "*", key)
+			if n.catchallName != "" {
+				m.pairs = append(m.pairs, ":"+n.catchallName, key)
+			}
+			if m.allows(n) {
+				return n, m.pairs
+			}
+			m.pairs = m.pairs[:mark]
+			break
 		}
 	}
-	// NotFound
+	return nil, nil
+}
+
+// next matches the rest of the path below node, the node itself if the path
+// was consumed, the params added by a failed match are removed
+func (m *matcher) next(node *Trie, rest string) *Trie {
+	mark := len(m.pairs)
+	if rest == "" {
+		if len(node.Handler) > 0 && m.allows(node) {
+			return node
+		}
+		return nil
+	}
+	if match, _ := m.dispatch(node, rest); match != nil {
+		return match
+	}
+	m.pairs = m.pairs[:mark]
+	return nil
+}
+
+// exact returns true if the static node has handlers and consumed the path,
+// it takes precedence over its siblings even if the method is not allowed
+func (m *matcher) exact(node *Trie, rest string) bool {
+	return rest == "" && len(node.Handler) > 0
+}
+
+// allows returns true if node handles the method, otherwise node is kept as
+// the 405 candidate if it is the first one
+func (m *matcher) allows(node *Trie) bool {
+	if m.router.methodHandler(node, m.method) != nil {
+		return true
+	}
+	if m.notAllowed == nil {
+		m.notAllowed = node
+		m.notAllowedPairs = append([]string(nil), m.pairs...)
+	}
+	return false
+}
+
+// prefixHandler handler registered for the path segments of a prefix
+type prefixHandler struct {
+	parts   []string
+	handler http.Handler
+}
+
+// groupNotFound returns the NotFound handler of the longest group prefix
+// matching path or nil
+func (r *Router) groupNotFound(path string) http.Handler {
+	segments := r.splitPath(path)
+	var (
+		handler http.Handler
+		longest = -1
+	)
+	for _, ph := range r.notFoundHandlers {
+		parts := ph.parts
+		if parts[0] == "/" {
+			parts = nil
+		}
+		if len(parts) <= longest || len(parts) > len(segments) {
+			continue
+		}
+		match := true
+		for i, part := range parts {
+			segment := segments[i]
+			if s, err := url.PathUnescape(segment); err == nil {
+				segment = s
+			}
+			switch {
+			case strings.HasPrefix(part, ":"):
+				match = r.matchDynamic(part, segment)
+			case r.CaseInsensitive:
+				match = strings.EqualFold(part, segment)
+			default:
+				match = part == segment
+			}
+			if !match {
+				break
+			}
+		}
+		if match {
+			handler, longest = ph.handler, len(parts)
+		}
+	}
+	return handler
+}
+
+// notFound returns the NotFoundHandler or http.NotFoundHandler if not set
+func (r *Router) notFound() http.Handler {
 	if r.NotFoundHandler != nil {
-		return r.NotFoundHandler, params
+		return r.NotFoundHandler
 	}
-	return http.NotFoundHandler(), params
+	return http.NotFoundHandler()
 }
 
-// ServeHTTP dispatches the handler registered in the matched path
+// ServeHTTP dispatches the handler registered in the matched path. The path
+// is split using the escaped form of the URL and every segment is decoded
+// before matching, "/files/a%2Fb" has the segments "files" and "a/b".
 func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	// panic handler
+	start := time.Now()
+
+	// context values
+	r.mu.RLock()
+	values := r.values
+	r.mu.RUnlock()
+	if len(values) > 0 {
+		ctx := req.Context()
+		for _, v := range values {
+			ctx = context.WithValue(ctx, v.key, v.val)
+		}
+		req = req.WithContext(ctx)
+	}
+
+	// client address behind a trusted proxy
+	if r.TrustProxy || len(r.TrustedProxies) > 0 {
+		if ip, ok := r.clientIP(req); ok {
+			r2 := *req
+			r2.RemoteAddr = ip
+			req = &r2
+		}
+	}
+
+	var (
+		rid string
+		ww  *ResponseWriter
+		rw  http.ResponseWriter = w
+	)
+
+	// panic handler, the response is written using the wrapped
+	// ResponseWriter so that the request is logged and measured with status
+	// 500, the default response is not sent if the handler already wrote the
+	// headers.
 	defer func() {
 		if err := recover(); err != nil {
-			log.Printf("panic: %s", err)
-			if r.PanicHandler != nil {
-				r.PanicHandler(w, req)
+			if rid != "" {
+				log.Printf("panic: %s [%s]", err, rid)
 			} else {
-				http.Error(w, http.StatusText(500), http.StatusInternalServerError)
+				log.Printf("panic: %s", err)
+			}
+			// the stack trace is truncated to 64KB
+			var stack []byte
+			if r.LogPanicStack || r.PanicHandlerWithError != nil {
+				stack = make([]byte, 64<<10)
+				stack = stack[:runtime.Stack(stack, false)]
+			}
+			// the stack is logged with the request when LogRequests is true
+			if r.LogPanicStack && (ww == nil || !r.LogRequests) {
+				log.Printf("panic stack:\n%s", stack)
+			}
+			if r.PanicHandlerWithError != nil {
+				r.PanicHandlerWithError(rw, req.WithContext(context.WithValue(req.Context(), panicStackKey, stack)), err)
+			} else if r.PanicHandler != nil {
+				r.PanicHandler(rw, req)
+			} else if ww == nil || !ww.wroteHeader {
+				r.panicResponse(rw)
+			}
+			if ww == nil {
+				return
+			}
+			if r.LogRequests {
+				ww.panic = fmt.Sprint(err)
+				if r.LogPanicStack {
+					ww.stack = string(stack)
+				}
+				r.log(ww, req)
+			}
+			if r.MetricsHook != nil {
+				r.MetricsHook(GetPattern(req), req.Method, ww.Status(), time.Since(start))
 			}
 		}
 	}()
 
 	// Request-ID
-	var rid string
 	if r.RequestID != "" {
-		if rid = req.Header.Get(r.RequestID); rid != "" {
-			w.Header().Set(r.RequestID, rid)
+		if rid = req.Header.Get(r.RequestID); rid == "" {
+			rid = newRequestID()
 		}
+		w.Header().Set(r.RequestID, rid)
+		req = req.WithContext(context.WithValue(req.Context(), requestIDKey, rid))
 	}
 
 	// wrap ResponseWriter
-	var ww *ResponseWriter
-	if r.LogRequests {
+	if r.LogRequests || r.MetricsHook != nil {
 		ww = NewResponseWriter(w, rid)
+		ww.start = start
+		rw = ww
+	}
+
+	// method override
+	if r.MethodOverrideHeader != "" && req.Method == http.MethodPost {
+		if m, ok := methodOverride(req, r.MethodOverrideHeader); ok {
+			r2 := *req
+			r2.Method = m
+			req = &r2
+		}
 	}
 
 	// set version based on the value of "Accept: application/vnd.*"
-	version := req.Header.Get("Accept")
-	if i := strings.LastIndex(version, versionHeader); i != -1 {
-		version = version[len(versionHeader)+i:]
+	var version string
+	if !r.DisableVersioning {
+		version = acceptVersion(req.Header.Get("Accept"), r.AcceptVersionPrefix, r.AcceptVersionSuffix)
+	}
+
+	// set version based on the query parameter VersionParam
+	if r.VersionParam != "" && req.URL.RawQuery != "" {
+		if v := req.URL.Query().Get(r.VersionParam); v != "" {
+			version = v
+		}
+	}
+
+	// set version based on the first path segment "/v2/..."
+	lookup := req.URL.EscapedPath()
+	if r.VersionFromPath {
+		if v, rest, ok := versionFromPath(lookup); ok {
+			version, lookup = v, rest
+		}
+	}
+
+	// query the path from left to right, using the routes of the host if
+	// any, the global middleware of the host Router runs after the parent's
+	var (
+		h       http.Handler
+		p       Params
+		pattern string
+	)
+	if router, ok := r.matchHost(req.Host); ok {
+		h, p, pattern = router.lookup(req, lookup, version)
+		r.mu.RLock()
+		h = r.chainHead(h)
+		r.mu.RUnlock()
 	} else {
-		version = ""
+		h, p, pattern = r.lookup(req, lookup, version)
+	}
+
+	// add query params
+	if r.QueryParams && req.URL.RawQuery != "" {
+		p = addQueryParams(p, req.URL.Query())
+	}
+
+	// dispatch request, the Params are always in the context when a route
+	// matched, even if empty, so handlers can assert them without checking
+	if pattern != "" && p == nil {
+		p = Params{}
+	}
+	if p != nil {
+		ctx := context.WithValue(req.Context(), ParamsKey, p)
+		if pattern != "" {
+			ctx = context.WithValue(ctx, PatternKey, pattern)
+		}
+		req = req.WithContext(ctx)
+	}
+	h.ServeHTTP(rw, req)
+
+	if r.LogRequests && (r.LogHealthCheck || !r.isHealthCheck(pattern)) {
+		r.log(ww, req)
+	}
+
+	if r.MetricsHook != nil {
+		r.MetricsHook(pattern, req.Method, ww.Status(), time.Since(start))
 	}
+}
 
-	// query the path from left to right
-	node, key, path, leaf := r.routes.Get(req.URL.Path, version)
+// panicResponse writes the default 500 response using the PanicResponse if
+// set
+func (r *Router) panicResponse(w http.ResponseWriter) {
+	if r.PanicResponse == nil {
+		http.Error(w, http.StatusText(500), http.StatusInternalServerError)
+		return
+	}
+	contentType := r.PanicContentType
+	if contentType == "" {
+		contentType = "text/plain; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write(r.PanicResponse)
+}
 
-	// dispatch the request
-	h, p := r.dispatch(node, key, path, req.Method, version, leaf, nil)
+// lookup returns the handler wrapped with the global middleware, the params
+// and the pattern of the matched route (empty if no route matched), it holds
+// the read lock only while searching the routes.
+func (r *Router) lookup(req *http.Request, path, version string) (http.Handler, Params, string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
-	// dispatch request
-	if r.LogRequests {
-		if p == nil {
-			h.ServeHTTP(ww, req)
-		} else {
-			h.ServeHTTP(ww, req.WithContext(context.WithValue(req.Context(), ParamsKey, p)))
+	// server-wide OPTIONS, asterisk-form request target
+	if req.Method == http.MethodOptions && req.RequestURI == "*" {
+		return r.chain(r.optionsAsterisk()), nil, ""
+	}
+
+	// redirect to the canonical path
+	if r.CleanPath && (req.Method == http.MethodGet || req.Method == http.MethodHead) {
+		if location, ok := cleanPath(req.URL.EscapedPath()); ok {
+			return r.chain(redirectHandler(location, req.Method)), nil, ""
 		}
-		r.Logger(ww, req)
-	} else {
-		if p == nil {
-			h.ServeHTTP(w, req)
-		} else {
-			h.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), ParamsKey, p)))
+	}
+
+	h, p, match := r.match(path, req.Method, version)
+
+	// retry using the default version
+	if match == nil && version != "" && r.DefaultVersion != "" && version != r.DefaultVersion {
+		h, p, match = r.match(path, req.Method, r.DefaultVersion)
+	}
+
+	// retry using the registered versions from the highest
+	if match == nil && version == "" && r.FallbackToUnversioned {
+		for _, v := range r.versions {
+			if vh, vp, vmatch := r.match(path, req.Method, v); vmatch != nil {
+				h, p, match = vh, vp, vmatch
+				break
+			}
+		}
+	}
+
+	// the other trailing slash form is not found unless redirected
+	if match != nil && r.StrictSlash && !r.RedirectTrailingSlash {
+		if _, ok := trailingSlashRedirect(req.URL.EscapedPath(), match); ok {
+			h, p, match = r.notFound(), nil, nil
 		}
 	}
+
+	// NotFound handler of the group
+	if match == nil && len(r.notFoundHandlers) > 0 {
+		if nf := r.groupNotFound(path); nf != nil {
+			h = nf
+		}
+	}
+
+	var pattern string
+	if match != nil {
+		pattern = match.pattern
+
+		// redirect to the registered trailing slash form
+		if r.RedirectTrailingSlash {
+			if location, ok := trailingSlashRedirect(req.URL.EscapedPath(), match); ok {
+				h = redirectHandler(location, req.Method)
+			}
+		}
+	}
+
+	return r.chainHead(h), p, pattern
+}
+
+// chainHead applies the global middleware like chain, the body of an
+// automatic HEAD response is discarded after it so that it sees the same body
+// as GET, e.g. ETag. The caller holds the read lock.
+func (r *Router) chainHead(h http.Handler) http.Handler {
+	if ah, ok := h.(autoHead); ok {
+		return headHandler(r.chain(ah.Handler))
+	}
+	return r.chain(h)
+}
+
+// methodOverride returns the method from the header or the "_method" form
+// field if it is a valid method
+func methodOverride(req *http.Request, header string) (string, bool) {
+	m := req.Header.Get(header)
+	if m == "" && strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		m = req.PostFormValue("_method")
+	}
+	switch m = strings.ToUpper(strings.TrimSpace(m)); m {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodOptions:
+		return m, true
+	}
+	return "", false
+}
+
+// versionFromPath returns the version from the first path segment if it
+// matches v[0-9]+ and the remaining path
+func versionFromPath(path string) (string, string, bool) {
+	p := strings.TrimLeft(path, "/")
+	segment, rest := p, "/"
+	if i := strings.Index(p, "/"); i != -1 {
+		segment, rest = p[:i], p[i:]
+	}
+	if len(segment) < 2 || segment[0] != 'v' {
+		return "", path, false
+	}
+	for _, c := range segment[1:] {
+		if c < '0' || c > '9' {
+			return "", path, false
+		}
+	}
+	return segment, rest, true
+}
+
+// trailingSlashRedirect returns the path with the trailing slash form of the
+// matched node, false if there is nothing to redirect. The leading slashes are
+// collapsed so that "//host/" is never used as a protocol relative location.
+func trailingSlashRedirect(path string, node *Trie) (string, bool) {
+	if node.path == "*" || node.path == "/" || path == "/" {
+		return "", false
+	}
+	path = "/" + strings.TrimLeft(path, "/")
+	hasSlash := strings.HasSuffix(path, "/")
+	if node.trailingSlash == hasSlash {
+		return "", false
+	}
+	if node.trailingSlash {
+		return path + "/", true
+	}
+	return strings.TrimRight(path, "/"), true
+}
+
+// cleanPath returns the canonical form of p if it is different, double
+// slashes and "." or ".." segments are removed keeping the trailing slash
+func cleanPath(p string) (string, bool) {
+	clean := path.Clean("/" + p)
+	if clean != "/" && strings.HasSuffix(p, "/") {
+		clean += "/"
+	}
+	return clean, clean != p
+}
+
+// redirectHandler redirects to location keeping the query string, 301 for
+// GET/HEAD and 308 for other methods so the method and body are kept.
+func redirectHandler(location, method string) http.Handler {
+	code := http.StatusMovedPermanently
+	if method != http.MethodGet && method != http.MethodHead {
+		code = http.StatusPermanentRedirect
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		url := location
+		if r.URL.RawQuery != "" {
+			url += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, url, code)
+	})
 }
 
 // splitPath returns an slice of the path
@@ -306,7 +1507,18 @@ func (r *Router) splitPath(p string) []string {
 	return pathParts
 }
 
+// GetPanicStack returns the stack trace of the recovered panic, available
+// within PanicHandlerWithError
+func GetPanicStack(r *http.Request) []byte {
+	if stack, ok := r.Context().Value(panicStackKey).([]byte); ok {
+		return stack
+	}
+	return nil
+}
+
 // GetError returns an error resulted from building a route, if any.
 func (r *Router) GetError() error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	return r.err
 }
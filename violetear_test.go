@@ -1,6 +1,7 @@
 package violetear
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"crypto/rand"
@@ -9,9 +10,13 @@ import (
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"reflect"
 	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/nbari/violetear/middleware"
 )
@@ -258,6 +263,110 @@ func TestPanic(t *testing.T) {
 	expect(t, res.StatusCode, http.StatusInternalServerError)
 }
 
+func TestPanicResponse(t *testing.T) {
+	tt := []struct {
+		name        string
+		contentType string
+		expect      string
+	}{
+		{"json", "application/json", "application/json"},
+		{"default content type", "", "text/plain; charset=utf-8"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			router := New()
+			router.Verbose = false
+			router.PanicResponse = []byte(`{"error":"internal"}`)
+			router.PanicContentType = tc.contentType
+			router.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+				panic("si si si")
+			})
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/panic", nil)
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, http.StatusInternalServerError)
+			expect(t, w.Header().Get("Content-Type"), tc.expect)
+			expect(t, w.Body.String(), `{"error":"internal"}`)
+		})
+	}
+}
+
+func TestPanicResponseWithPanicHandler(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.PanicHandler = myPanicHandler()
+	router.PanicResponse = []byte(`{"error":"internal"}`)
+	router.PanicContentType = "application/json"
+	router.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("ja ja ja")
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/panic", nil)
+	router.ServeHTTP(w, req)
+	expect(t, w.Code, http.StatusInternalServerError)
+	expect(t, w.Body.String(), "ne ne ne\n")
+}
+
+func TestLogPanicStack(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		var buf bytes.Buffer
+		log.SetOutput(&buf)
+		router := New()
+		router.Verbose = false
+		router.LogPanicStack = enabled
+		router.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+			panic("si si si")
+		})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/panic", nil)
+		router.ServeHTTP(w, req)
+		log.SetOutput(os.Stderr)
+		expect(t, w.Code, http.StatusInternalServerError)
+		expect(t, strings.Contains(buf.String(), "panic: si si si"), true)
+		expect(t, strings.Contains(buf.String(), "panic stack:"), enabled)
+		expect(t, strings.Contains(buf.String(), "TestLogPanicStack"), enabled)
+	}
+}
+
+func TestLogPanicStackLogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	router := New()
+	router.Verbose = false
+	router.LogPanicStack = true
+	router.LogRequests = true
+	router.LogSampleRate = 0.5
+	router.logSample = func() float64 { return 0.9 }
+	var entry LogEntry
+	router.LogHandler = func(e LogEntry) {
+		entry = e
+	}
+	router.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("si si si")
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/panic", nil)
+	router.ServeHTTP(w, req)
+	expect(t, w.Code, http.StatusInternalServerError)
+	expect(t, entry.Panic, "si si si")
+	expect(t, strings.Contains(entry.Stack, "TestLogPanicStackLogHandler"), true)
+	expect(t, strings.Contains(buf.String(), "panic stack:"), false)
+
+	var stack string
+	router.LogHandler = nil
+	router.Logger = func(ww *ResponseWriter, r *http.Request) {
+		stack = ww.Stack()
+	}
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	expect(t, strings.Contains(stack, "TestLogPanicStackLogHandler"), true)
+	expect(t, strings.Contains(buf.String(), "panic stack:"), false)
+}
+
 func TestPanicHandler(t *testing.T) {
 	router := New()
 	router.PanicHandler = myPanicHandler()
@@ -394,7 +503,7 @@ func TestRequestIdCreate(t *testing.T) {
 	req, _ := http.NewRequest("GET", "/", nil)
 	router.ServeHTTP(w, req)
 	expect(t, w.Code, 200)
-	expect(t, len(w.HeaderMap.Get("Request-ID")), 0)
+	expect(t, len(w.HeaderMap.Get("Request-ID")), 36)
 }
 
 func TestHandleFuncMethods(t *testing.T) {
@@ -769,3 +878,1928 @@ func TestReturnedTrieChaining(t *testing.T) {
 		})
 	}
 }
+
+func TestUse(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	var order []string
+	mw := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	router.Use(mw("m1"), mw("m2"))
+	router.Use(mw("m3"))
+	router.AddRegex(":id", `\d+`)
+	router.HandleFunc("/test/:id", func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "handler")
+	}, "GET")
+
+	tt := []struct {
+		name   string
+		method string
+		path   string
+		code   int
+		order  []string
+	}{
+		{"handler", "GET", "/test/1", 200, []string{"m1", "m2", "m3", "handler"}},
+		{"not found", "GET", "/foo", 404, []string{"m1", "m2", "m3"}},
+		{"not allowed", "POST", "/test/1", 405, []string{"m1", "m2", "m3"}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			order = nil
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tc.method, tc.path, nil)
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, tc.code)
+			expectDeepEqual(t, order, tc.order)
+		})
+	}
+}
+
+func TestUseShortCircuit(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.AddRegex(":id", `\d+`)
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			expect(t, GetParam("id", r), "1")
+			if r.Header.Get("Authorization") == "" {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	called := false
+	router.HandleFunc("/test/:id", func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/test/1", nil)
+	router.ServeHTTP(w, req)
+	expect(t, w.Code, 401)
+	expect(t, called, false)
+
+	w = httptest.NewRecorder()
+	req.Header.Set("Authorization", "secret")
+	router.ServeHTTP(w, req)
+	expect(t, w.Code, 200)
+	expect(t, called, true)
+}
+
+func TestWithValue(t *testing.T) {
+	type ctxKey string
+	router := New()
+	router.Verbose = false
+	router.WithValue(ctxKey("db"), "postgres")
+	router.WithValue(ctxKey("env"), "dev")
+	router.WithValue(ctxKey("env"), "prod")
+	router.AddRegex(":id", `\d+`)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(fmt.Sprintf("%s %s %s", r.Context().Value(ctxKey("db")), r.Context().Value(ctxKey("env")), GetParam("id", r))))
+	}
+	router.HandleFunc("/users/:id", handler)
+	router.Host("api.example.com").HandleFunc("/users/:id", handler)
+
+	for _, target := range []string{"/users/1", "http://api.example.com/users/1"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", target, nil)
+		router.ServeHTTP(w, req)
+		expect(t, w.Body.String(), "postgres prod 1")
+	}
+
+	for _, k := range []interface{}{nil, ParamsKey, PatternKey} {
+		func() {
+			defer func() {
+				expect(t, recover() != nil, true)
+			}()
+			router.WithValue(k, "x")
+		}()
+	}
+}
+
+func TestHandleChain(t *testing.T) {
+	type ctxKey string
+	router := New()
+	router.Verbose = false
+	var order []string
+	auth := func(w http.ResponseWriter, r *http.Request) *http.Request {
+		order = append(order, "auth")
+		user := r.Header.Get("Authorization")
+		if user == "" {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return nil
+		}
+		return r.WithContext(context.WithValue(r.Context(), ctxKey("user"), user))
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) *http.Request {
+		order = append(order, "handler")
+		w.Write([]byte(r.Context().Value(ctxKey("user")).(string) + " " + GetParam("*", r)))
+		return nil
+	}
+	never := func(w http.ResponseWriter, r *http.Request) *http.Request {
+		t.Error("handler after the response should not be called")
+		return nil
+	}
+	// the request of the caller is not modified by the chain
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+			if r.Context().Value(ctxKey("user")) != nil {
+				t.Error("the chain should not modify the request of the caller")
+			}
+		})
+	})
+	expect(t, router.HandleChain("/admin/*", []ChainHandler{auth, handler, never}, "GET") != nil, true)
+
+	tt := []struct {
+		name  string
+		auth  string
+		code  int
+		body  string
+		order []string
+	}{
+		{"unauthorized", "", 401, "Unauthorized\n", []string{"auth"}},
+		{"authorized", "alice", 200, "alice panel", []string{"auth", "handler"}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			order = nil
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/admin/panel", nil)
+			if tc.auth != "" {
+				req.Header.Set("Authorization", tc.auth)
+			}
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, tc.code)
+			expect(t, w.Body.String(), tc.body)
+			expectDeepEqual(t, order, tc.order)
+		})
+	}
+
+	expect(t, router.HandleChain("/empty", nil) == nil, true)
+	expect(t, router.GetError() != nil, true)
+}
+
+func TestHandleWithMiddleware(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	var order []string
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			order = append(order, "global")
+			next.ServeHTTP(w, r)
+		})
+	})
+	auth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			order = append(order, "auth")
+			expect(t, GetParam("*", r), "panel")
+			if r.Header.Get("Authorization") != "secret" {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "handler")
+	}
+	router.HandleWithMiddleware("/admin/*", http.HandlerFunc(handler), []func(http.Handler) http.Handler{auth}, "GET")
+	router.HandleFunc("/public/*", handler, "GET")
+
+	tt := []struct {
+		name  string
+		path  string
+		auth  string
+		code  int
+		order []string
+	}{
+		{"admin", "/admin/panel", "", 401, []string{"global", "auth"}},
+		{"admin authorized", "/admin/panel", "secret", 200, []string{"global", "auth", "handler"}},
+		{"public", "/public/panel", "", 200, []string{"global", "handler"}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			order = nil
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tc.path, nil)
+			if tc.auth != "" {
+				req.Header.Set("Authorization", tc.auth)
+			}
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, tc.code)
+			expectDeepEqual(t, order, tc.order)
+		})
+	}
+}
+
+func TestURL(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	for _, v := range dynamicRoutes {
+		router.AddRegex(v.name, v.regex)
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	router.HandleNamed("root", "/", handler)
+	router.HandleNamed("item", "/root/:uuid/item", handler, "GET")
+	router.HandleNamed("ip", "/ping/:ip/:id/#v2", handler, "GET")
+	router.HandleNamed("static", "/static/*", handler, "GET")
+	router.AddRegex(":page", `\d+`)
+	router.HandleNamed("posts", "/posts/:page?", handler, "GET")
+	router.HandleFunc("/archive/{year:[0-9]+}?", handler, "GET").Name("archive")
+	expect(t, router.GetError(), nil)
+
+	tt := []struct {
+		name   string
+		route  string
+		params map[string]string
+		url    string
+		err    bool
+	}{
+		{"root", "root", nil, "/", false},
+		{"item", "item", map[string]string{"uuid": "A97F0AF3-043D-4376-82BE-CD6C1A524E0E"}, "/root/A97F0AF3-043D-4376-82BE-CD6C1A524E0E/item", false},
+		{"item colon", "item", map[string]string{":uuid": "A97F0AF3-043D-4376-82BE-CD6C1A524E0E"}, "/root/A97F0AF3-043D-4376-82BE-CD6C1A524E0E/item", false},
+		{"item missing", "item", nil, "", true},
+		{"item bad regex", "item", map[string]string{"uuid": "foo"}, "", true},
+		{"ip", "ip", map[string]string{"ip": "127.0.0.1", "id": "3"}, "/ping/127.0.0.1/3", false},
+		{"ip bad id", "ip", map[string]string{"ip": "127.0.0.1", "id": "x"}, "", true},
+		{"static", "static", map[string]string{"*": "/css/app.css"}, "/static/css/app.css", false},
+		{"static missing", "static", nil, "", true},
+		{"posts", "posts", map[string]string{"page": "2"}, "/posts/2", false},
+		{"posts optional", "posts", nil, "/posts", false},
+		{"posts bad page", "posts", map[string]string{"page": "x"}, "", true},
+		{"archive", "archive", map[string]string{"year": "2024"}, "/archive/2024", false},
+		{"archive optional", "archive", nil, "/archive", false},
+		{"not found", "foo", nil, "", true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			u, err := router.URL(tc.route, tc.params)
+			expect(t, err != nil, tc.err)
+			expect(t, u, tc.url)
+		})
+	}
+
+	// named routes also set the route name in the context
+	router.HandleNamed("named", "/named", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		expect(t, GetRouteName(r), "named")
+	}))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/named", nil)
+	router.ServeHTTP(w, req)
+	expect(t, w.Code, 200)
+}
+
+func TestOptionsAsterisk(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.AutoHead = true
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("catchall"))
+	}
+	router.HandleFunc("/", handler, "GET")
+	router.HandleFunc("/users", handler, "POST")
+	router.HandleFunc("/users/*", handler, "DELETE,PATCH")
+
+	req, err := http.ReadRequest(bufio.NewReader(strings.NewReader("OPTIONS * HTTP/1.1\r\nHost: example.com\r\n\r\n")))
+	expect(t, err, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	expect(t, w.Code, 200)
+	expect(t, w.Header().Get("Allow"), "DELETE, GET, HEAD, OPTIONS, PATCH, POST")
+	expect(t, w.Body.String(), "")
+
+	// MethodAll allows the standard methods
+	router.HandleFunc("/all", handler)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	expect(t, w.Header().Get("Allow"), "DELETE, GET, HEAD, OPTIONS, PATCH, POST, PUT")
+
+	// origin-form request to "*" is a path
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("OPTIONS", "/*", nil)
+	router.ServeHTTP(w, req)
+	expect(t, w.Code, 404)
+}
+
+func TestRootPath(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	handler := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		}
+	}
+	router.HandleFunc("/", handler("root"))
+
+	tt := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/", 200, "root"},
+		{"/anything", 404, "404 page not found\n"},
+		{"/anything/else", 404, "404 page not found\n"},
+	}
+	for _, tc := range tt {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		router.ServeHTTP(w, req)
+		expect(t, w.Code, tc.code)
+		expect(t, w.Body.String(), tc.body)
+	}
+
+	// the catch-all doesn't replace the root handler
+	router.HandleFunc("*", handler("catchall"))
+	for path, body := range map[string]string{
+		"/":              "root",
+		"/anything":      "catchall",
+		"/anything/else": "catchall",
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(w, req)
+		expect(t, w.Code, 200)
+		expect(t, w.Body.String(), body)
+	}
+}
+
+func TestRootCatchall(t *testing.T) {
+	handler := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		}
+	}
+	tt := []struct {
+		method string
+		path   string
+		code   int
+		body   string
+	}{
+		{"GET", "/", 200, "root"},
+		{"GET", "/x", 200, "catchall"},
+		{"GET", "/x/y", 200, "catchall"},
+		{"POST", "/", 405, "Method Not Allowed\n"},
+		{"POST", "/x", 200, "catchall"},
+	}
+	// the registration order doesn't matter
+	for _, rootFirst := range []bool{true, false} {
+		router := New()
+		router.Verbose = false
+		if rootFirst {
+			router.HandleFunc("/", handler("root"), "GET")
+		}
+		router.HandleFunc("/*", handler("catchall"))
+		if !rootFirst {
+			router.HandleFunc("/", handler("root"), "GET")
+		}
+		for _, tc := range tt {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tc.method, tc.path, nil)
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, tc.code)
+			expect(t, w.Body.String(), tc.body)
+		}
+	}
+}
+
+func TestHandleChained(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.AddRegex(":id", `\d+`)
+	mw := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("X-Chain", name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(GetRouteName(r) + " " + GetParam("id", r)))
+	}
+	router.HandleFunc("/users/:id", handler, "GET").Use(mw("a"), mw("b")).Name("user")
+	router.HandleFunc("/v2/users/:id", handler).Name("user-v2")
+	expect(t, router.GetError(), nil)
+
+	url, err := router.URL("user", map[string]string{"id": "7"})
+	expect(t, err, nil)
+	expect(t, url, "/users/7")
+	url, err = router.URL("user-v2", map[string]string{"id": "7"})
+	expect(t, err, nil)
+	expect(t, url, "/v2/users/7")
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", url, nil)
+	router.ServeHTTP(w, req)
+	expect(t, w.Body.String(), "user-v2 7")
+	expect(t, len(w.Header()["X-Chain"]), 0)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/users/7", nil)
+	router.ServeHTTP(w, req)
+	expect(t, w.Body.String(), "user 7")
+	expectDeepEqual(t, w.Header()["X-Chain"], []string{"a", "b"})
+
+	// failed routes can be chained, the error is kept
+	expect(t, router.HandleFunc("/posts/:slug", handler).Use(mw("a")).Priority(1).Name("post") == nil, true)
+	expect(t, router.GetError() != nil, true)
+	_, err = router.URL("post", nil)
+	expect(t, err != nil, true)
+}
+
+func TestRouteHandle(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.AddRegex(":id", `\d+`)
+	auth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		})
+	}
+	mw := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Chain", "mw")
+			next.ServeHTTP(w, r)
+		})
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(GetRouteName(r) + " " + GetParam("id", r)))
+	}
+
+	// Use wraps only the handlers of the Handle call
+	router.HandleFunc("/x", handler, "GET")
+	router.HandleFunc("/x", handler, "POST").Use(auth)
+
+	// Version moves the handlers to the version
+	router.HandleFunc("/items/:id", handler, "GET").Name("item").Version("v2").Use(mw)
+	router.HandleFunc("/items/:id", handler, "POST")
+	expect(t, router.GetError(), nil)
+	url, err := router.URL("item", map[string]string{"id": "1"})
+	expect(t, err, nil)
+	expect(t, url, "/items/1")
+
+	tt := []struct {
+		method  string
+		path    string
+		version string
+		code    int
+		body    string
+		chain   string
+	}{
+		{"GET", "/x", "", 200, " ", ""},
+		{"POST", "/x", "", 401, "Unauthorized\n", ""},
+		{"GET", "/items/1", "", 405, "Method Not Allowed\n", ""},
+		{"POST", "/items/1", "", 200, " 1", ""},
+		{"GET", "/items/1", "v2", 200, "item 1", "mw"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.method+tc.path+tc.version, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tc.method, tc.path, nil)
+			if tc.version != "" {
+				req.Header.Set("Accept", "application/vnd."+tc.version)
+			}
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, tc.code)
+			expect(t, w.Body.String(), tc.body)
+			expect(t, w.Header().Get("X-Chain"), tc.chain)
+		})
+	}
+	expect(t, router.HandleFunc("/none/:none", handler).Version("v2") == nil, true)
+}
+
+func TestAutoOptions(t *testing.T) {
+	tt := []struct {
+		name        string
+		autoOptions bool
+		path        string
+		code        int
+		allow       string
+	}{
+		{"disabled", false, "/test", 405, "GET, POST"},
+		{"enabled", true, "/test", 204, "GET, POST, OPTIONS"},
+		{"explicit handler wins", true, "/options", 200, ""},
+		{"all", true, "/all", 200, ""},
+		{"catchall", true, "/catch/foo", 204, "PUT, OPTIONS"},
+		{"not found", true, "/none", 404, ""},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			router := New()
+			router.Verbose = false
+			router.AutoOptions = tc.autoOptions
+			handler := func(w http.ResponseWriter, r *http.Request) {}
+			router.HandleFunc("/test", handler, "GET")
+			router.HandleFunc("/test", handler, "POST, GET")
+			router.HandleFunc("/options", handler, "GET,OPTIONS")
+			router.HandleFunc("/all", handler)
+			router.HandleFunc("/catch/*", handler, "PUT")
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("OPTIONS", tc.path, nil)
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, tc.code)
+			expect(t, w.Header().Get("Allow"), tc.allow)
+		})
+	}
+}
+
+func TestAutoHead(t *testing.T) {
+	tt := []struct {
+		name     string
+		autoHead bool
+		path     string
+		code     int
+	}{
+		{"disabled", false, "/test", 405},
+		{"enabled", true, "/test", 201},
+		{"explicit handler wins", true, "/head", 204},
+		{"no get handler", true, "/post", 405},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			router := New()
+			router.Verbose = false
+			router.LogRequests = true
+			router.Logger = func(w *ResponseWriter, r *http.Request) {
+				if r.Method == "HEAD" && w.Status() < 400 {
+					expect(t, w.Size(), 0)
+				}
+			}
+			router.AutoHead = tc.autoHead
+			get := func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Content-Length", "15")
+				w.Header().Set("X-Test", "test")
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(`{"hello":"get"}`))
+			}
+			router.HandleFunc("/test", get, "GET")
+			router.HandleFunc("/head", get, "GET")
+			router.HandleFunc("/head", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			}, "HEAD")
+			router.HandleFunc("/post", get, "POST")
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("HEAD", tc.path, nil)
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, tc.code)
+			if tc.code == 201 {
+				expect(t, w.Body.Len(), 0)
+				wGet := httptest.NewRecorder()
+				req, _ := http.NewRequest("GET", tc.path, nil)
+				router.ServeHTTP(wGet, req)
+				expectDeepEqual(t, w.Header(), wGet.Header())
+				expect(t, wGet.Body.String(), `{"hello":"get"}`)
+			}
+		})
+	}
+}
+
+func TestAutoHeadContentLength(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.AutoHead = true
+	router.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}, "GET")
+	router.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("hi"))
+	}, "GET")
+	router.HandleFunc("/empty", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}, "GET")
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	for _, path := range []string{"/hello", "/status", "/empty"} {
+		get, err := http.Get(ts.URL + path)
+		expect(t, err, nil)
+		get.Body.Close()
+		head, err := http.Head(ts.URL + path)
+		expect(t, err, nil)
+		head.Body.Close()
+		expect(t, head.StatusCode, get.StatusCode)
+		expect(t, head.Header.Get("Content-Length"), get.Header.Get("Content-Length"))
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("HEAD", "/hello", nil)
+	router.ServeHTTP(w, req)
+	expect(t, w.Header().Get("Content-Length"), "5")
+	expect(t, w.Body.Len(), 0)
+}
+
+func TestRedirectTrailingSlash(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.RedirectTrailingSlash = true
+	router.AddRegex(":id", `\d+`)
+	handler := func(w http.ResponseWriter, r *http.Request) {}
+	router.HandleFunc("/", handler)
+	router.HandleFunc("/hello/", handler)
+	router.HandleFunc("/world", handler)
+	router.HandleFunc("/user/:id/", handler)
+	router.HandleFunc("/static/*", handler)
+
+	tt := []struct {
+		name     string
+		method   string
+		path     string
+		code     int
+		location string
+	}{
+		{"root", "GET", "/", 200, ""},
+		{"add slash", "GET", "/hello", 301, "/hello/"},
+		{"add slash query", "GET", "/hello?foo=bar&a=1", 301, "/hello/?foo=bar&a=1"},
+		{"add slash post", "POST", "/hello", 308, "/hello/"},
+		{"registered form", "GET", "/hello/", 200, ""},
+		{"strip slash", "HEAD", "/world/", 301, "/world"},
+		{"strip slash put", "PUT", "/world/", 308, "/world"},
+		{"registered form no slash", "GET", "/world", 200, ""},
+		{"dynamic", "GET", "/user/1", 301, "/user/1/"},
+		{"dynamic registered form", "GET", "/user/1/", 200, ""},
+		{"catchall", "GET", "/static/foo/", 200, ""},
+		{"not found", "GET", "/none/", 404, ""},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tc.method, tc.path, nil)
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, tc.code)
+			expect(t, w.Header().Get("Location"), tc.location)
+		})
+	}
+
+	// disabled
+	router.RedirectTrailingSlash = false
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/hello", nil)
+	router.ServeHTTP(w, req)
+	expect(t, w.Code, 200)
+}
+
+func TestRedirectTrailingSlashHost(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.RedirectTrailingSlash = true
+	router.AddRegex(":slug", `[\w.]+`)
+	router.HandleFunc("/:slug", func(w http.ResponseWriter, r *http.Request) {})
+	router.HandleFunc("/:slug/x/", func(w http.ResponseWriter, r *http.Request) {})
+
+	tt := []struct {
+		path     string
+		location string
+	}{
+		{"//evil.com/", "/evil.com"},
+		{"///evil.com/", "/evil.com"},
+		{"//evil.com/x", "/evil.com/x/"},
+	}
+	for _, tc := range tt {
+		// the request line "GET //evil.com/ HTTP/1.1" keeps the path
+		req, _ := http.ReadRequest(bufio.NewReader(strings.NewReader("GET " + tc.path + " HTTP/1.1\r\nHost: example.com\r\n\r\n")))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		expect(t, w.Code, 301)
+		expect(t, w.Header().Get("Location"), tc.location)
+	}
+}
+
+func TestStrictSlash(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.AddRegex(":id", `\d+`)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok" + GetParam("id", r)))
+	}
+	router.HandleFunc("/", handler)
+	router.HandleFunc("/hello/", handler)
+	router.HandleFunc("/world", handler)
+	router.HandleFunc("/user/:id/", handler)
+	router.HandleFunc("/static/*", handler)
+
+	tt := []struct {
+		path   string
+		body   string
+		strict int
+	}{
+		{"/", "ok", 200},
+		{"/hello", "ok", 404},
+		{"/hello/", "ok", 200},
+		{"/world", "ok", 200},
+		{"/world/", "ok", 404},
+		{"/user/1", "ok1", 404},
+		{"/user/1/", "ok1", 200},
+		{"/static/a/", "ok", 200},
+	}
+	for _, strict := range []bool{false, true} {
+		router.StrictSlash = strict
+		for _, tc := range tt {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tc.path, nil)
+			router.ServeHTTP(w, req)
+			expect(t, w.Header().Get("Location"), "")
+			if strict {
+				expect(t, w.Code, tc.strict)
+				if tc.strict != 200 {
+					continue
+				}
+			} else {
+				expect(t, w.Code, 200)
+			}
+			expect(t, w.Body.String(), tc.body)
+		}
+	}
+
+	// the other form is redirected instead
+	expect(t, New().StrictSlash, false)
+	router.RedirectTrailingSlash = true
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/hello", nil)
+	router.ServeHTTP(w, req)
+	expect(t, w.Code, 301)
+	expect(t, w.Header().Get("Location"), "/hello/")
+}
+
+func TestCaseInsensitive(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.AddRegex(":name", `^[A-Z][a-z]+$`)
+	router.HandleFunc("/hello/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})
+	router.HandleFunc("/hello/World/:name", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(GetParam("name", r)))
+	})
+	router.HandleFunc("/static/*", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(GetParam("*", r)))
+	})
+
+	tt := []struct {
+		name            string
+		caseInsensitive bool
+		path            string
+		code            int
+		body            string
+	}{
+		{"sensitive", false, "/HELLO/", 404, "404 page not found\n"},
+		{"sensitive exact", false, "/hello/", 200, "hello"},
+		{"insensitive", true, "/HELLO/", 200, "hello"},
+		{"insensitive mixed", true, "/HeLLo", 200, "hello"},
+		{"insensitive param", true, "/hello/world/Alice", 200, "Alice"},
+		{"insensitive param original case", true, "/HELLO/WORLD/alice", 404, "404 page not found\n"},
+		{"insensitive catchall", true, "/STATIC/Foo", 200, "Foo"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			router.CaseInsensitive = tc.caseInsensitive
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tc.path, nil)
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, tc.code)
+			expect(t, w.Body.String(), tc.body)
+		})
+	}
+}
+
+type panicValue struct {
+	code int
+}
+
+func TestPanicHandlerWithError(t *testing.T) {
+	tt := []struct {
+		name  string
+		value interface{}
+	}{
+		{"string", "si si si"},
+		{"error", http.ErrAbortHandler},
+		{"struct", panicValue{42}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			router := New()
+			router.Verbose = false
+			router.PanicHandler = func(w http.ResponseWriter, r *http.Request) {
+				t.Error("PanicHandler should not be called")
+			}
+			router.PanicHandlerWithError = func(w http.ResponseWriter, r *http.Request, err interface{}) {
+				expect(t, err, tc.value)
+				expect(t, bytes.Contains(GetPanicStack(r), []byte("TestPanicHandlerWithError")), true)
+				http.Error(w, fmt.Sprint(err), http.StatusInternalServerError)
+			}
+			router.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+				panic(tc.value)
+			})
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/panic", nil)
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, http.StatusInternalServerError)
+			expect(t, w.Body.String(), fmt.Sprint(tc.value)+"\n")
+			expect(t, len(GetPanicStack(req)), 0)
+		})
+	}
+}
+
+func TestMetricsHook(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	for _, v := range dynamicRoutes {
+		router.AddRegex(v.name, v.regex)
+	}
+	type metric struct {
+		pattern string
+		method  string
+		status  int
+	}
+	var metrics []metric
+	router.MetricsHook = func(pattern string, method string, status int, duration time.Duration) {
+		expect(t, duration > 0, true)
+		metrics = append(metrics, metric{pattern, method, status})
+	}
+	router.HandleFunc("/root/:uuid/item", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}, "POST")
+	router.HandleFunc("/static/*", func(w http.ResponseWriter, r *http.Request) {})
+
+	requests := []struct {
+		method string
+		path   string
+	}{
+		{"POST", "/root/" + genUUID() + "/item"},
+		{"POST", "/root/" + genUUID() + "/item"},
+		{"GET", "/root/" + genUUID() + "/item"},
+		{"GET", "/static/app.css"},
+		{"GET", "/missing"},
+	}
+	for _, req := range requests {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(req.method, req.path, nil)
+		router.ServeHTTP(w, req)
+	}
+	expectDeepEqual(t, metrics, []metric{
+		{"/root/:uuid/item", "POST", 201},
+		{"/root/:uuid/item", "POST", 201},
+		{"/root/:uuid/item", "GET", 405},
+		{"/static/*", "GET", 200},
+		{"", "GET", 404},
+	})
+}
+
+func TestMetricsHookPanic(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	var (
+		pattern string
+		status  int
+	)
+	router.MetricsHook = func(p string, method string, s int, duration time.Duration) {
+		pattern, status = p, s
+	}
+	router.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("si si si")
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/panic", nil)
+	router.ServeHTTP(w, req)
+	expect(t, w.Code, 500)
+	expect(t, pattern, "/panic")
+	expect(t, status, 500)
+}
+
+func TestVersionFromPath(t *testing.T) {
+	tt := []struct {
+		path    string
+		version string
+		rest    string
+		ok      bool
+	}{
+		{"/v2/users", "v2", "/users", true},
+		{"/v10", "v10", "/", true},
+		{"/v1/", "v1", "/", true},
+		{"//v3/users/1", "v3", "/users/1", true},
+		{"/users", "", "/users", false},
+		{"/v/users", "", "/v/users", false},
+		{"/v2a/users", "", "/v2a/users", false},
+		{"/", "", "/", false},
+	}
+	for _, tc := range tt {
+		v, rest, ok := versionFromPath(tc.path)
+		expect(t, v, tc.version)
+		expect(t, rest, tc.rest)
+		expect(t, ok, tc.ok)
+	}
+
+	router := New()
+	router.Verbose = false
+	router.VersionFromPath = true
+	router.AddRegex(":id", `\d+`)
+	handler := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body + GetParam("id", r)))
+		}
+	}
+	router.HandleFunc("/users", handler("users"))
+	router.HandleFunc("/users#v2", handler("users v2"))
+	router.HandleFunc("/users/:id#v2", handler("user v2 "))
+	router.HandleFunc("/users#violetear.v3", handler("users accept v3"))
+	router.HandleFunc("/", handler("root"))
+	router.HandleFunc("/#v2", handler("root v2"))
+
+	rt := []struct {
+		path   string
+		accept string
+		code   int
+		body   string
+	}{
+		{"/users", "", 200, "users"},
+		{"/v2/users", "", 200, "users v2"},
+		{"/v2/users/7", "", 200, "user v2 7"},
+		{"/v2", "", 200, "root v2"},
+		{"/v3/users", "", 404, "404 page not found\n"},
+		{"/users", "application/vnd.violetear.v3", 200, "users accept v3"},
+		{"/v2/users", "application/vnd.violetear.v3", 200, "users v2"},
+	}
+	for _, tc := range rt {
+		t.Run(tc.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tc.path, nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, tc.code)
+			expect(t, w.Body.String(), tc.body)
+		})
+	}
+}
+
+func TestVersionParam(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.VersionParam = "api_version"
+	router.VersionFromPath = true
+	handler := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		}
+	}
+	router.HandleFunc("/users", handler("users"))
+	router.HandleFunc("/users#2", handler("users 2"))
+	router.HandleFunc("/users#v3", handler("users v3"))
+	router.HandleFunc("/users#violetear.v4", handler("users accept v4"))
+
+	tt := []struct {
+		name   string
+		path   string
+		accept string
+		code   int
+		body   string
+	}{
+		{"no version", "/users", "", 200, "users"},
+		{"param", "/users?api_version=2", "", 200, "users 2"},
+		{"param over accept", "/users?api_version=2", "application/vnd.violetear.v4", 200, "users 2"},
+		{"missing param", "/users?foo=bar", "application/vnd.violetear.v4", 200, "users accept v4"},
+		{"empty param", "/users?api_version=", "application/vnd.violetear.v4", 200, "users accept v4"},
+		{"unknown version", "/users?api_version=5", "", 404, "404 page not found\n"},
+		{"path over param", "/v3/users?api_version=2", "", 200, "users v3"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tc.path, nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, tc.code)
+			expect(t, w.Body.String(), tc.body)
+		})
+	}
+}
+
+func TestDefaultVersion(t *testing.T) {
+	handler := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		}
+	}
+	tt := []struct {
+		name           string
+		defaultVersion string
+		path           string
+		accept         string
+		code           int
+		body           string
+	}{
+		{"no default unknown version", "", "/users", "application/vnd.violetear.v3", 404, "404 page not found\n"},
+		{"no default unversioned", "", "/users", "", 200, "users"},
+		{"default unknown version", "violetear.v1", "/users", "application/vnd.violetear.v3", 200, "users v1"},
+		{"default known version", "violetear.v1", "/users", "application/vnd.violetear.v2", 200, "users v2"},
+		{"default unversioned", "violetear.v1", "/users", "", 200, "users"},
+		{"default version only in default", "violetear.v1", "/old", "application/vnd.violetear.v2", 200, "old v1"},
+		{"default missing path", "violetear.v1", "/none", "application/vnd.violetear.v2", 404, "404 page not found\n"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			router := New()
+			router.Verbose = false
+			router.DefaultVersion = tc.defaultVersion
+			router.HandleFunc("/users", handler("users"))
+			router.HandleFunc("/users#violetear.v1", handler("users v1"))
+			router.HandleFunc("/users#violetear.v2", handler("users v2"))
+			router.HandleFunc("/old#violetear.v1", handler("old v1"))
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tc.path, nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, tc.code)
+			expect(t, w.Body.String(), tc.body)
+		})
+	}
+}
+
+func TestHandleFuncVariadicMethods(t *testing.T) {
+	tt := []struct {
+		name    string
+		methods []string
+		allowed []string
+		denied  []string
+	}{
+		{"comma", []string{"GET,HEAD"}, []string{"GET", "HEAD"}, []string{"POST"}},
+		{"variadic", []string{"GET", "HEAD"}, []string{"GET", "HEAD"}, []string{"POST"}},
+		{"mixed", []string{"GET,HEAD", " post ", "put, delete"}, []string{"GET", "HEAD", "POST", "PUT", "DELETE"}, []string{"PATCH"}},
+		{"empty elements", []string{"", " get ", " ", "head"}, []string{"GET", "HEAD"}, []string{"POST"}},
+		{"all", []string{" ", ""}, []string{"GET", "POST", "PATCH"}, nil},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			router := New()
+			router.Verbose = false
+			router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {}, tc.methods...)
+			for _, m := range tc.allowed {
+				w := httptest.NewRecorder()
+				req, _ := http.NewRequest(m, "/", nil)
+				router.ServeHTTP(w, req)
+				expect(t, w.Code, 200)
+			}
+			for _, m := range tc.denied {
+				w := httptest.NewRecorder()
+				req, _ := http.NewRequest(m, "/", nil)
+				router.ServeHTTP(w, req)
+				expect(t, w.Code, 405)
+			}
+		})
+	}
+}
+
+func TestRemoveRoute(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.AddRegex(":id", `\d+`)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}
+	router.HandleFunc("/a", handler)
+	router.HandleFunc("/a/b", handler)
+	router.HandleFunc("/a/:id", handler, "GET", "POST")
+	router.HandleFunc("/a/*", handler)
+	router.HandleNamed("c", "/c", http.HandlerFunc(handler))
+	router.HandleFunc("/c#v2", handler)
+
+	expect(t, router.RemoveRoute("/x") != nil, true)
+	expect(t, router.RemoveRoute("/a/b", "PUT") != nil, true)
+	expect(t, router.RemoveRoute("/a/b"), nil)
+	expect(t, router.RemoveRoute("/a/b") != nil, true)
+	expect(t, router.RemoveRoute("/a/:id", "post"), nil)
+	expect(t, router.RemoveRoute("/a/*"), nil)
+	expect(t, router.RemoveRoute("/c#v2"), nil)
+
+	tt := []struct {
+		path    string
+		method  string
+		version string
+		code    int
+	}{
+		{"/a", "GET", "", 200},
+		{"/a/b", "GET", "", 404},
+		{"/a/1", "GET", "", 200},
+		{"/a/1", "POST", "", 405},
+		{"/a/x/y", "GET", "", 404},
+		{"/c", "GET", "", 200},
+		{"/c", "GET", "v2", 404},
+	}
+	for _, tc := range tt {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(tc.method, tc.path, nil)
+		if tc.version != "" {
+			req.Header.Set("Accept", "application/vnd.violetear."+tc.version)
+		}
+		router.ServeHTTP(w, req)
+		expect(t, w.Code, tc.code)
+	}
+
+	u, err := router.URL("c", nil)
+	expect(t, err, nil)
+	expect(t, u, "/c")
+	expect(t, router.RemoveRoute("/c"), nil)
+	_, err = router.URL("c", nil)
+	expect(t, err != nil, true)
+}
+
+func TestRemoveRouteRegexRefs(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.AddRegex(":id", `\d+`)
+	handler := func(w http.ResponseWriter, r *http.Request) {}
+	router.HandleFunc("/a/:id", handler, "GET")
+	router.HandleFunc("/a/:id", handler, "POST")
+	router.HandleFunc("/b/:id", handler, "GET,PUT")
+	router.HandleFunc("/u/{uid:[0-9]+}", handler)
+	router.HandleFunc("/f/*file", handler)
+	expectDeepEqual(t, router.regexCache.refs, map[string]int{":id": 4, ":uid{[0-9]+}": 1})
+
+	expect(t, router.RemoveRoute("/a/:id", "POST"), nil)
+	expectDeepEqual(t, router.regexCache.refs, map[string]int{":id": 3, ":uid{[0-9]+}": 1})
+	expect(t, router.RemoveRoute("/a/:id"), nil)
+	expect(t, router.RemoveRoute("/b/:id"), nil)
+	expect(t, router.RemoveRoute("/u/{uid:[0-9]+}"), nil)
+	expectDeepEqual(t, router.regexCache.refs, map[string]int{})
+	expect(t, router.RemoveRoute("/f/*file"), nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/f/x", nil)
+	router.ServeHTTP(w, req)
+	expect(t, w.Code, 404)
+}
+
+func TestConcurrentHandle(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf(":id%d", i)
+			router.AddRegex(name, `\d+`)
+			router.HandleFunc(fmt.Sprintf("/r%d/%s", i, name), func(w http.ResponseWriter, r *http.Request) {})
+			router.HandleNamed(fmt.Sprintf("r%d", i), fmt.Sprintf("/n%d", i), http.NotFoundHandler())
+			router.Use(func(h http.Handler) http.Handler { return h })
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				w := httptest.NewRecorder()
+				req, _ := http.NewRequest("GET", fmt.Sprintf("/r%d/%d", i, j), nil)
+				router.ServeHTTP(w, req)
+				router.URL(fmt.Sprintf("r%d", i), nil)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	expect(t, router.GetError(), nil)
+	for i := 0; i < 10; i++ {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/r%d/1", i), nil)
+		router.ServeHTTP(w, req)
+		expect(t, w.Code, 200)
+	}
+}
+
+func TestMatchSideEffectFree(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.AddRegex(":id", `\d+`)
+	router.AddRegex(":word", `\w+`)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(GetParam("id", r) + GetParam("word", r) + GetParam("*", r)))
+	}
+	router.HandleFunc("/users/:id/:word", handler)
+	router.HandleFunc("/users/:word/posts", handler)
+	router.HandleFunc("/users/*", handler)
+	routes := router.Routes()
+
+	paths := []string{"/users/1/a", "/users/b/posts", "/users/2/posts", "/users/c-d/e"}
+	parts := make([][]string, len(paths))
+	for i, path := range paths {
+		parts[i] = router.splitPath(path)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, path := range paths {
+				w := httptest.NewRecorder()
+				req, _ := http.NewRequest("GET", path, nil)
+				router.ServeHTTP(w, req)
+				if req.URL.Path != path {
+					t.Errorf("Expected: %s Got: %s", path, req.URL.Path)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, path := range paths {
+		expectDeepEqual(t, router.splitPath(path), parts[i])
+	}
+	expectDeepEqual(t, router.Routes(), routes)
+	for path, body := range map[string]string{
+		"/users/1/a":     "1a",
+		"/users/b/posts": "b",
+		"/users/2/posts": "2posts",
+		"/users/c-d/e":   "c-d",
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(w, req)
+		expect(t, w.Body.String(), body)
+	}
+}
+
+func TestRedirect(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.AddRegex(":id", `\d+`)
+	expect(t, router.Redirect("/old", "/new", 200) != nil, true)
+	expect(t, router.Redirect("/old", "/new", http.StatusMovedPermanently), nil)
+	expect(t, router.Redirect("/u/:id", "/users/:id", http.StatusFound, "GET"), nil)
+	expect(t, router.Redirect("/a/:id/:id", "/b/:id/x/:id", http.StatusTemporaryRedirect), nil)
+	expect(t, router.Redirect("/files/*", "/static/*", http.StatusMovedPermanently), nil)
+	expect(t, router.Redirect("/x/:none", "/y", http.StatusFound) != nil, true)
+
+	tt := []struct {
+		method   string
+		path     string
+		code     int
+		location string
+	}{
+		{"GET", "/old", 301, "/new"},
+		{"GET", "/old?q=1", 301, "/new?q=1"},
+		{"GET", "/u/7", 302, "/users/7"},
+		{"POST", "/u/7", 405, ""},
+		{"GET", "/u/x", 404, ""},
+		{"POST", "/a/1/2", 307, "/b/1/x/2"},
+		{"GET", "/files/main.css", 301, "/static/main.css"},
+	}
+	for _, tc := range tt {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(tc.method, tc.path, nil)
+		router.ServeHTTP(w, req)
+		expect(t, w.Code, tc.code)
+		expect(t, w.Header().Get("Location"), tc.location)
+	}
+}
+
+func TestOptionalSegment(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.AddRegex(":page", `\d+`)
+	router.HandleFunc("/posts/:page?", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("page=" + GetParam("page", r)))
+	})
+	router.HandleFunc("/:page?", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("root=" + GetParam("page", r)))
+	})
+	expect(t, router.GetError(), nil)
+
+	tt := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/posts", 200, "page="},
+		{"/posts/", 200, "page="},
+		{"/posts/2", 200, "page=2"},
+		{"/posts/x", 404, "404 page not found\n"},
+		{"/", 200, "root="},
+		{"/3", 200, "root=3"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tc.path, nil)
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, tc.code)
+			expect(t, w.Body.String(), tc.body)
+		})
+	}
+
+	for _, path := range []string{"/posts/:page?/edit", "/posts/*?", "/a/?"} {
+		router := New()
+		router.Verbose = false
+		router.AddRegex(":page", `\d+`)
+		expect(t, router.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {}) == nil, true)
+		expect(t, router.GetError() != nil, true)
+	}
+}
+
+func TestEscapedPath(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.AddRegex(":name", `.+`)
+	router.AddRegex(":word", `[a-z ]+`)
+	router.HandleFunc("/files/:name", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("file " + GetParam("name", r)))
+	})
+	router.HandleFunc("/words/:word", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("word " + GetParam("word", r)))
+	})
+	router.HandleFunc("/hello world", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("static"))
+	})
+	router.HandleFunc("/all/*", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("all " + GetParam("*", r)))
+	})
+
+	tt := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/files/my%2Ffile", 200, "file my/file"},
+		{"/files/my/file", 404, "404 page not found\n"},
+		{"/files/a%20b", 200, "file a b"},
+		{"/words/hello%20world", 200, "word hello world"},
+		{"/words/hello%2Fworld", 404, "404 page not found\n"},
+		{"/hello%20world", 200, "static"},
+		{"/all/a%2Fb", 200, "all a/b"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", tc.path, nil)
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, tc.code)
+			expect(t, w.Body.String(), tc.body)
+		})
+	}
+}
+
+func TestMethodOverride(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.MethodOverrideHeader = "X-HTTP-Method-Override"
+	for _, m := range []string{"POST", "PUT", "DELETE"} {
+		m := m
+		router.HandleFunc("/item", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(m + " " + r.Method))
+		}, m)
+	}
+
+	tt := []struct {
+		name   string
+		method string
+		header string
+		form   string
+		code   int
+		body   string
+	}{
+		{"no override", "POST", "", "", 200, "POST POST"},
+		{"header", "POST", "DELETE", "", 200, "DELETE DELETE"},
+		{"header lower case", "POST", "put", "", 200, "PUT PUT"},
+		{"form", "POST", "", "_method=DELETE", 200, "DELETE DELETE"},
+		{"header over form", "POST", "PUT", "_method=DELETE", 200, "PUT PUT"},
+		{"invalid method", "POST", "FOO", "", 200, "POST POST"},
+		{"not allowed", "POST", "PATCH", "", 405, "Method Not Allowed\n"},
+		{"only POST", "GET", "DELETE", "", 405, "Method Not Allowed\n"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tc.method, "/item", strings.NewReader(tc.form))
+			if tc.form != "" {
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+			if tc.header != "" {
+				req.Header.Set("X-HTTP-Method-Override", tc.header)
+			}
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, tc.code)
+			expect(t, w.Body.String(), tc.body)
+		})
+	}
+}
+
+func TestCleanPath(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.CleanPath = true
+	router.HandleFunc("/a/b", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ab"))
+	})
+
+	tt := []struct {
+		method   string
+		path     string
+		code     int
+		location string
+	}{
+		{"GET", "/a/b", 200, ""},
+		{"GET", "//a//b", 301, "/a/b"},
+		{"HEAD", "/a//b", 301, "/a/b"},
+		{"GET", "/a/./b", 301, "/a/b"},
+		{"GET", "/a/c/../b", 301, "/a/b"},
+		{"GET", "/a//b/", 301, "/a/b/"},
+		{"GET", "/a//b?x=1", 301, "/a/b?x=1"},
+		{"POST", "//a//b", 200, ""},
+	}
+	for _, tc := range tt {
+		t.Run(tc.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, tc.code)
+			expect(t, w.Header().Get("Location"), tc.location)
+		})
+	}
+}
+
+func TestGreedyCatchall(t *testing.T) {
+	for _, greedy := range []bool{false, true} {
+		router := New()
+		router.Verbose = false
+		router.GreedyCatchall = greedy
+		router.AddRegex(":id", `\d+`)
+		handler := func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(GetParam("*", r)))
+		}
+		router.HandleFunc("/static/*", handler)
+		router.HandleFunc("/items/:id/*", handler)
+		router.HandleFunc("*", handler)
+
+		tt := []struct {
+			path   string
+			greedy string
+			first  string
+		}{
+			{"/static/app.css", "app.css", "app.css"},
+			{"/static/css/app.css", "css/app.css", "css"},
+			{"/static/a/b/c/", "a/b/c/", "a"},
+			{"/static/a%2Fb/c%20d", "a/b/c d", "a/b"},
+			{"/items/1/a/b", "a/b", "a"},
+			{"/x/y/z", "x/y/z", "x"},
+		}
+		for _, tc := range tt {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", tc.path, nil)
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, 200)
+			if greedy {
+				expect(t, w.Body.String(), tc.greedy)
+			} else {
+				expect(t, w.Body.String(), tc.first)
+			}
+		}
+	}
+}
+
+func TestNamedCatchall(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.HandleFunc("/files/*filepath", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(GetAllParams(r).Get("filepath") + " " + GetParam("filepath", r) + " " + GetParam("*", r)))
+	})
+	router.HandleFunc("/static/*", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(GetParam("*", r)))
+	})
+	router.HandleNamed("files", "/files/*filepath", http.NotFoundHandler())
+	expect(t, router.GetError(), nil)
+
+	tt := []struct {
+		path string
+		body string
+	}{
+		{"/files/a.txt", "a.txt a.txt a.txt"},
+		{"/files/dir/a.txt", "dir/a.txt dir/a.txt dir/a.txt"},
+		{"/static/dir/a.txt", "dir"},
+	}
+	for _, tc := range tt {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		router.ServeHTTP(w, req)
+		expect(t, w.Code, 200)
+		expect(t, w.Body.String(), tc.body)
+	}
+
+	u, err := router.URL("files", map[string]string{"filepath": "dir/a.txt"})
+	expect(t, err, nil)
+	expect(t, u, "/files/dir/a.txt")
+
+	expect(t, router.Routes()[0].Path, "/files/*filepath")
+	expect(t, router.RemoveRoute("/files/*filepath"), nil)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/files/a.txt", nil)
+	router.ServeHTTP(w, req)
+	expect(t, w.Code, 404)
+
+	expect(t, router.HandleFunc("/files/*filepath/x", func(w http.ResponseWriter, r *http.Request) {}) == nil, true)
+}
+
+func TestDeepCatchall(t *testing.T) {
+	for _, greedy := range []bool{false, true} {
+		for _, fallback := range []bool{false, true} {
+			router := New()
+			router.Verbose = false
+			router.GreedyCatchall = greedy
+			router.CatchallFallback = fallback
+			router.AddRegex(":ver", `^v\d+$`)
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(GetPattern(r) + " " + GetParam("*", r)))
+			}
+			router.HandleFunc("/api/v1/assets/*", handler)
+			router.HandleFunc("/api/v1/users", handler)
+			router.HandleFunc("/api/:ver/files/*", handler)
+			router.HandleFunc("/cdn/:ver/*", handler)
+			router.HandleFunc("/cdn/:ver/static/*", handler)
+			router.HandleFunc("/api/*", handler)
+			router.AddRegex(":id", `\d+`)
+			router.HandleFunc("/d/*", handler)
+			router.HandleFunc("/d/:id/x", handler)
+
+			tt := []struct {
+				path     string
+				fallback bool
+				greedy   string
+				first    string
+			}{
+				{"/api/v1/assets/logo.png", false, "/api/v1/assets/* logo.png", "/api/v1/assets/* logo.png"},
+				{"/api/v1/assets/img/logo.png", false, "/api/v1/assets/* img/logo.png", "/api/v1/assets/* img"},
+				{"/api/v1/users", false, "/api/v1/users ", "/api/v1/users "},
+				{"/api/v2/files/a/b", false, "/api/:ver/files/* a/b", "/api/:ver/files/* a"},
+				{"/api/other", false, "/api/* other", "/api/* other"},
+				{"/cdn/v1/a/b", false, "/cdn/:ver/* a/b", "/cdn/:ver/* a"},
+				{"/cdn/v1/static/a/b", false, "/cdn/:ver/static/* a/b", "/cdn/:ver/static/* a"},
+				{"/d/1/x", false, "/d/:id/x ", "/d/:id/x "},
+				{"/d/a", false, "/d/* a", "/d/* a"},
+				// the segment matches a static or dynamic node, the
+				// catch-all of the same level is only used with
+				// CatchallFallback
+				{"/api/v1", true, "/api/* v1", "/api/* v1"},
+				{"/api/v1/other", true, "/api/* v1/other", "/api/* v1"},
+				{"/api/v1/assets", true, "/api/* v1/assets", "/api/* v1"},
+				{"/api/v1/other/logo.png", true, "/api/* v1/other/logo.png", "/api/* v1"},
+				{"/d/1", true, "/d/* 1", "/d/* 1"},
+				{"/d/1/y", true, "/d/* 1/y", "/d/* 1"},
+			}
+			for _, tc := range tt {
+				w := httptest.NewRecorder()
+				req, _ := http.NewRequest("GET", tc.path, nil)
+				router.ServeHTTP(w, req)
+				if tc.fallback && !fallback {
+					expect(t, w.Code, 404)
+					continue
+				}
+				expect(t, w.Code, 200)
+				if greedy {
+					expect(t, w.Body.String(), tc.greedy)
+				} else {
+					expect(t, w.Body.String(), tc.first)
+				}
+			}
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/other", nil)
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, 404)
+		}
+	}
+}
+
+func TestStaticPrecedence(t *testing.T) {
+	for _, literalFirst := range []bool{true, false} {
+		router := New()
+		router.Verbose = false
+		router.AddRegex(":id", `\w+`)
+		literal := func() {
+			router.HandleFunc("/users/admin", func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("admin"))
+			})
+		}
+		if literalFirst {
+			literal()
+		}
+		router.HandleFunc("/users/:id", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("id " + GetParam("id", r)))
+		})
+		if !literalFirst {
+			literal()
+		}
+
+		tt := []struct {
+			path string
+			body string
+		}{
+			{"/users/admin", "admin"},
+			{"/users/42", "id 42"},
+			{"/users/admins", "id admins"},
+		}
+		for _, tc := range tt {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tc.path, nil)
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, 200)
+			expect(t, w.Body.String(), tc.body)
+		}
+	}
+}
+
+func TestMethodAll(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	handler := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		}
+	}
+	router.HandleFunc("/both", handler("get"), "GET")
+	router.HandleFunc("/both", handler("all"), MethodAll)
+	router.HandleFunc("/star", handler("star"), "*")
+	router.HandleFunc("/star", handler("post"), "post")
+	expect(t, router.GetError(), nil)
+
+	tt := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{"GET", "/both", "get"},
+		{"POST", "/both", "all"},
+		{"DELETE", "/both", "all"},
+		{"GET", "/star", "star"},
+		{"POST", "/star", "post"},
+	}
+	for _, tc := range tt {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(tc.method, tc.path, nil)
+		router.ServeHTTP(w, req)
+		expect(t, w.Code, 200)
+		expect(t, w.Body.String(), tc.body)
+	}
+
+	expectDeepEqual(t, router.Routes()[1].Methods, []string{"ALL", "POST"})
+	expect(t, router.RemoveRoute("/star", "*"), nil)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/star", nil)
+	router.ServeHTTP(w, req)
+	expect(t, w.Code, 405)
+}
+
+func TestMethodAllRegistrationOrder(t *testing.T) {
+	for _, allFirst := range []bool{true, false} {
+		router := New()
+		router.Verbose = false
+		router.AddRegex(":id", `\d+`)
+		for _, path := range []string{"/static", "/users/:id", "/files/*"} {
+			all := func() {
+				router.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+					w.Write([]byte("all"))
+				})
+			}
+			if allFirst {
+				all()
+			}
+			router.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("get"))
+			}, "GET")
+			if !allFirst {
+				all()
+			}
+		}
+
+		for _, path := range []string{"/static", "/users/1", "/files/a"} {
+			for method, body := range map[string]string{"GET": "get", "DELETE": "all", "POST": "all"} {
+				w := httptest.NewRecorder()
+				req, _ := http.NewRequest(method, path, nil)
+				router.ServeHTTP(w, req)
+				expect(t, w.Code, 200)
+				expect(t, w.Body.String(), body)
+			}
+		}
+	}
+}
+
+func TestPriority(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.AddRegex(":id", `\d+`)
+	router.AddRegex(":word", `\w+`)
+	handler := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body + GetParam("word", r) + GetParam("id", r)))
+		}
+	}
+	router.HandleFunc("/users/admin", handler("literal"), "GET,POST")
+	router.HandleFunc("/users/admin/settings", handler("settings"))
+	router.HandleFunc("/users/:word", handler("word "), "GET").Priority(10)
+	router.HandleFunc("/users/:word/posts", handler("posts "))
+	router.HandleFunc("/items/:word", handler("word "))
+	router.HandleFunc("/items/:id", handler("id ")).Priority(1)
+	router.HandleFunc("/files/static", handler("static"))
+	router.HandleFunc("/files/:word", handler("word "))
+	router.HandleFunc("/files/*", handler("catchall")).Priority(5)
+	router.HandleFunc("/pages/about", handler("literal"))
+	router.HandleFunc("/pages/:word", handler("get "), "GET").Priority(10)
+	router.HandleFunc("/pages/:word", handler("post "), "POST")
+	expect(t, router.GetError(), nil)
+
+	tt := []struct {
+		method string
+		path   string
+		code   int
+		body   string
+	}{
+		{"GET", "/users/admin", 200, "word admin"},
+		{"GET", "/users/bob", 200, "word bob"},
+		{"GET", "/users/admin/posts", 200, "posts admin"},
+		{"GET", "/users/admin/settings", 200, "settings"},
+		{"POST", "/users/admin", 200, "literal"},
+		{"POST", "/users/bob", 405, "Method Not Allowed\n"},
+		{"GET", "/items/42", 200, "id 42"},
+		{"GET", "/items/abc", 200, "word abc"},
+		{"GET", "/files/static", 200, "static"},
+		{"GET", "/files/x", 200, "word x"},
+		{"GET", "/files/x-y", 200, "catchall"},
+		{"GET", "/pages/about", 200, "get about"},
+		{"POST", "/pages/about", 200, "literal"},
+		{"POST", "/pages/bob", 200, "post bob"},
+	}
+	for _, tc := range tt {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(tc.method, tc.path, nil)
+		router.ServeHTTP(w, req)
+		expect(t, w.Code, tc.code)
+		expect(t, w.Body.String(), tc.body)
+	}
+
+	// the literal is used again once the weighted route is removed
+	expect(t, router.RemoveRoute("/users/:word"), nil)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/users/admin", nil)
+	router.ServeHTTP(w, req)
+	expect(t, w.Body.String(), "literal")
+}
+
+func TestMethodNotAllowedAllow(t *testing.T) {
+	tt := []struct {
+		name       string
+		autoHead   bool
+		notAllowed bool
+		path       string
+		allow      string
+	}{
+		{"default", false, false, "/test", "GET, POST"},
+		{"auto head", true, false, "/test", "GET, POST, HEAD"},
+		{"custom handler", false, true, "/test", "GET, POST"},
+		{"dynamic", false, false, "/item/1", "PUT"},
+		{"catchall", false, false, "/catch/foo", "DELETE"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			router := New()
+			router.Verbose = false
+			router.AutoHead = tc.autoHead
+			router.AddRegex(":id", `\d+`)
+			if tc.notAllowed {
+				router.NotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					http.Error(w, "custom", http.StatusMethodNotAllowed)
+				})
+			}
+			handler := func(w http.ResponseWriter, r *http.Request) {}
+			router.HandleFunc("/test", handler, "GET, POST")
+			router.HandleFunc("/item/:id", handler, "PUT")
+			router.HandleFunc("/catch/*", handler, "DELETE")
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("PATCH", tc.path, nil)
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, 405)
+			expect(t, w.Header().Get("Allow"), tc.allow)
+			if tc.notAllowed {
+				expect(t, w.Body.String(), "custom\n")
+			}
+		})
+	}
+}
+
+func TestNotFoundOrNotAllowed(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	for _, v := range dynamicRoutes {
+		router.AddRegex(v.name, v.regex)
+	}
+	router.AddRegex(":word", `\w+`)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(GetPattern(r)))
+	}
+	router.HandleFunc("/a/b/:word", handler, "GET")
+	router.HandleFunc("/x/:id", handler, "GET")
+	router.HandleFunc("/x/:word", handler, "POST")
+	router.HandleFunc("/c/*", handler, "GET")
+	router.HandleFunc("/d/:uuid", handler, "GET")
+	router.HandleFunc("/d/:uuid/e", handler, "GET")
+	router.HandleFunc("/f/:id", handler, "GET")
+	router.HandleFunc("/f/*", handler, "PUT")
+
+	uuid := genUUID()
+	tt := []struct {
+		method string
+		path   string
+		code   int
+		body   string
+	}{
+		{"GET", "/a/b", 404, ""},
+		{"GET", "/a/b/c", 200, "/a/b/:word"},
+		{"GET", "/x/1", 200, "/x/:id"},
+		{"POST", "/x/1", 200, "/x/:word"},
+		{"PUT", "/x/1", 405, ""},
+		{"POST", "/c/a", 405, ""},
+		{"POST", "/c/a/b", 405, ""},
+		{"GET", "/c", 404, ""},
+		{"POST", "/d/" + uuid, 405, ""},
+		{"POST", "/d/" + uuid + "/", 405, ""},
+		{"POST", "/d/" + uuid + "/e", 405, ""},
+		{"GET", "/d/" + uuid + "/x", 404, ""},
+		{"GET", "/f/1", 200, "/f/:id"},
+		{"PUT", "/f/1", 200, "/f/*"},
+		{"POST", "/f/1", 405, ""},
+	}
+	for _, tc := range tt {
+		t.Run(tc.method+tc.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tc.method, tc.path, nil)
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, tc.code)
+			if tc.code == 200 {
+				expect(t, w.Body.String(), tc.body)
+			}
+		})
+	}
+}
+
+func TestVersionedRoutes(t *testing.T) {
+	router := New()
+	router.Verbose = false
+	router.AddRegex(":id", `\d+`)
+	text := func(s string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(s + " " + GetParam("id", r) + GetParam("*", r)))
+		}
+	}
+	router.HandleFunc("/users#2", text("users v2"), "GET")
+	router.HandleFunc("/users#1", text("users v1"), "GET")
+	router.HandleFunc("/users", text("users"), "GET")
+	router.HandleFunc("/users#1", text("users post v1"), "POST")
+	router.HandleFunc("/users/:id#2", text("user v2"), "GET")
+	router.HandleFunc("/users/:id#1", text("user v1"), "GET")
+	router.HandleFunc("/static/*#2", text("static v2"), "GET")
+	router.HandleFunc("/static/*", text("static"), "GET")
+
+	tt := []struct {
+		method  string
+		path    string
+		version string
+		code    int
+		body    string
+	}{
+		{"GET", "/users", "", 200, "users "},
+		{"GET", "/users", "1", 200, "users v1 "},
+		{"GET", "/users", "2", 200, "users v2 "},
+		{"POST", "/users", "1", 200, "users post v1 "},
+		{"POST", "/users", "2", 405, "Method Not Allowed\n"},
+		{"GET", "/users", "3", 404, "404 page not found\n"},
+		{"GET", "/users/7", "1", 200, "user v1 7"},
+		{"GET", "/users/7", "2", 200, "user v2 7"},
+		{"GET", "/users/7", "", 404, "404 page not found\n"},
+		{"GET", "/static/a", "", 200, "static a"},
+		{"GET", "/static/a", "2", 200, "static v2 a"},
+		{"GET", "/static/a", "1", 404, "404 page not found\n"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.method+tc.path+"#"+tc.version, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tc.method, tc.path, nil)
+			if tc.version != "" {
+				req.Header.Set("Accept", "application/vnd.api+json;version="+tc.version)
+			}
+			router.ServeHTTP(w, req)
+			expect(t, w.Code, tc.code)
+			expect(t, w.Body.String(), tc.body)
+		})
+	}
+}